@@ -0,0 +1,41 @@
+package antssnippet
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+	"github.com/stretchr/testify/assert"
+
+	metersnippet "github.com/A0dongq1N/golang_snippet/meter"
+)
+
+// 用 Meter 统计协程池的吞吐和任务延迟：排队延迟 = 开始执行 - 提交，执行延迟 = 结束 - 开始执行
+func TestPoolMeter(t *testing.T) {
+	queued := metersnippet.NewMeter(metersnippet.MeterConfig{Name: "ants.queue", Window: time.Second})
+	run := metersnippet.NewMeter(metersnippet.MeterConfig{Name: "ants.run", Window: time.Second})
+
+	pool, _ := ants.NewPool(10)
+	defer pool.Release()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		submitted := time.Now()
+		err := pool.Submit(func() {
+			defer wg.Done()
+			queued.Since(submitted)
+			run.Time(func() { time.Sleep(time.Millisecond) })
+		})
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	var report strings.Builder
+	assert.NoError(t, metersnippet.WriteText(&report, queued.Snapshot(), run.Snapshot()))
+	t.Log("\n" + report.String())
+	assert.Equal(t, int64(200), run.Count())
+	assert.GreaterOrEqual(t, run.Snapshot().Latency.P50, time.Millisecond)
+}
@@ -0,0 +1,281 @@
+package breakersnippet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+熔断器：下游持续失败时快速失败，不再把请求压到已经出问题的依赖上。
+
+	Closed   ──失败率超过阈值──▶ Open
+	Open     ──OpenTimeout 后──▶ HalfOpen
+	HalfOpen ──探测全部成功──▶ Closed
+	HalfOpen ──任一探测失败──▶ Open
+
+- 失败率按滚动窗口统计：窗口被分成 Buckets 个桶，过期的桶整体丢弃
+- 窗口内请求数少于 MinRequests 时不熔断，避免低流量时一两次失败就跳闸
+- OnStateChange 在状态切换时回调（在锁外调用），可用于打日志、上报指标
+*/
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+var (
+	// ErrOpen 熔断器打开，请求被直接拒绝
+	ErrOpen = errors.New("breakersnippet: circuit breaker is open")
+	// ErrTooManyProbes 半开状态下探测请求数已满
+	ErrTooManyProbes = errors.New("breakersnippet: too many requests in half-open state")
+)
+
+// BreakerConfig 熔断器配置，零值字段使用默认值
+type BreakerConfig struct {
+	Window         time.Duration        // 滚动窗口长度，默认 10s
+	Buckets        int                  // 窗口分桶数，默认 10；超过 Window 的纳秒数时减少到每桶 1ns
+	MinRequests    int                  // 窗口内至少多少请求才计算失败率，默认 20
+	FailureRatio   float64              // 失败率阈值，默认 0.5
+	OpenTimeout    time.Duration        // 打开多久后进入半开，默认 5s
+	HalfOpenProbes int                  // 半开时允许的探测请求数，全部成功才关闭，默认 1
+	IsFailure      func(err error) bool // 哪些错误计为失败，默认除 nil 和 context.Canceled 以外的错误
+	OnStateChange  func(from, to State) // 状态切换回调
+	Clock          timesnippet.Clock    // 为空时使用 timesnippet.RealClock
+}
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// Breaker 熔断器，并发安全
+type Breaker struct {
+	cfg        BreakerConfig
+	bucketSize time.Duration
+
+	mu       sync.Mutex
+	state    State
+	openedAt time.Time
+	buckets  []bucket
+	probes   int // 半开状态下已放行的探测数
+	passed   int // 半开状态下已成功的探测数
+	pending  []transition
+}
+
+type transition struct {
+	from, to State
+}
+
+// NewBreaker 创建熔断器
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 10
+	}
+	// 桶长度至少 1ns，否则计算桶下标时除以 0
+	if time.Duration(cfg.Buckets) > cfg.Window {
+		cfg.Buckets = int(cfg.Window)
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.FailureRatio <= 0 || cfg.FailureRatio > 1 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 5 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		}
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	return &Breaker{
+		cfg:        cfg,
+		bucketSize: cfg.Window / time.Duration(cfg.Buckets),
+		buckets:    make([]bucket, cfg.Buckets),
+	}
+}
+
+// State 当前状态（Open 超时后读取会得到 HalfOpen）
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	b.advanceLocked(b.cfg.Clock.Now())
+	state, events := b.state, b.takeEventsLocked()
+	b.mu.Unlock()
+	b.notify(events)
+	return state
+}
+
+// Counts 滚动窗口内的成功、失败次数
+func (b *Breaker) Counts() (successes, failures int) {
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.countsLocked(now)
+}
+
+// Allow 申请执行一次调用。返回的 done 必须在调用结束后以调用结果调用一次
+func (b *Breaker) Allow() (done func(err error), err error) {
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	b.advanceLocked(now)
+	switch b.state {
+	case StateOpen:
+		err = ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			err = ErrTooManyProbes
+		} else {
+			b.probes++
+		}
+	}
+	state, events := b.state, b.takeEventsLocked()
+	b.mu.Unlock()
+	b.notify(events)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(callErr error) {
+		once.Do(func() { b.record(state, b.cfg.IsFailure(callErr)) })
+	}, nil
+}
+
+// Do 在熔断器保护下执行 op
+func (b *Breaker) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = op(ctx)
+	done(err)
+	return err
+}
+
+func (b *Breaker) record(admittedIn State, failed bool) {
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	b.advanceLocked(now)
+
+	// 放行时的状态已经过期（例如半开探测期间又被别的探测打开了），结果只计入统计
+	switch {
+	case b.state == StateHalfOpen && admittedIn == StateHalfOpen:
+		if failed {
+			b.setStateLocked(StateOpen, now)
+			break
+		}
+		b.passed++
+		if b.passed >= b.cfg.HalfOpenProbes {
+			b.setStateLocked(StateClosed, now)
+		}
+	case b.state == StateClosed:
+		bk := b.bucketLocked(now)
+		if !failed {
+			bk.successes++
+			break
+		}
+		bk.failures++
+		if s, f := b.countsLocked(now); s+f >= b.cfg.MinRequests && float64(f)/float64(s+f) >= b.cfg.FailureRatio {
+			b.setStateLocked(StateOpen, now)
+		}
+	}
+	events := b.takeEventsLocked()
+	b.mu.Unlock()
+	b.notify(events)
+}
+
+// advanceLocked Open 超时后转为 HalfOpen
+func (b *Breaker) advanceLocked(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setStateLocked(StateHalfOpen, now)
+	}
+}
+
+// setStateLocked 切换状态，切换事件暂存到 pending，解锁后再回调
+func (b *Breaker) setStateLocked(to State, now time.Time) {
+	if b.state == to {
+		return
+	}
+	b.pending = append(b.pending, transition{from: b.state, to: to})
+	b.state = to
+	b.probes, b.passed = 0, 0
+	switch to {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		// 重新开始统计，避免刚恢复就被窗口里的旧失败再次打开
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+}
+
+func (b *Breaker) takeEventsLocked() []transition {
+	events := b.pending
+	b.pending = nil
+	return events
+}
+
+func (b *Breaker) notify(events []transition) {
+	if b.cfg.OnStateChange == nil {
+		return
+	}
+	for _, e := range events {
+		b.cfg.OnStateChange(e.from, e.to)
+	}
+}
+
+// bucketLocked 返回 now 所在的桶，桶里是上一轮的旧数据时先清空
+func (b *Breaker) bucketLocked(now time.Time) *bucket {
+	start := now.Truncate(b.bucketSize)
+	idx := int(start.UnixNano()/int64(b.bucketSize)) % len(b.buckets)
+	bk := &b.buckets[idx]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+func (b *Breaker) countsLocked(now time.Time) (successes, failures int) {
+	oldest := now.Truncate(b.bucketSize).Add(-b.cfg.Window + b.bucketSize)
+	for _, bk := range b.buckets {
+		if !bk.start.IsZero() && !bk.start.Before(oldest) && !bk.start.After(now) {
+			successes += bk.successes
+			failures += bk.failures
+		}
+	}
+	return successes, failures
+}
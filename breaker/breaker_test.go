@@ -0,0 +1,150 @@
+package breakersnippet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// clockStart 测试里 FakeClock 的起始时刻
+var clockStart = time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+
+var errDown = errors.New("down")
+
+func call(b *Breaker, err error) error {
+	return b.Do(context.Background(), func(context.Context) error { return err })
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("状态切换", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		var changes []string
+		b := NewBreaker(BreakerConfig{
+			MinRequests: 4,
+			OpenTimeout: time.Second,
+			Clock:       clock,
+			OnStateChange: func(from, to State) {
+				changes = append(changes, from.String()+"->"+to.String())
+			},
+		})
+
+		// 请求数不足 MinRequests 时不熔断
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, call(b, errDown), errDown)
+		}
+		assert.Equal(t, StateClosed, b.State())
+
+		assert.ErrorIs(t, call(b, errDown), errDown)
+		assert.Equal(t, StateOpen, b.State())
+		assert.ErrorIs(t, call(b, nil), ErrOpen)
+
+		clock.Advance(time.Second)
+		assert.Equal(t, StateHalfOpen, b.State())
+
+		// 探测失败重新打开
+		assert.ErrorIs(t, call(b, errDown), errDown)
+		assert.Equal(t, StateOpen, b.State())
+
+		clock.Advance(time.Second)
+		assert.NoError(t, call(b, nil))
+		assert.Equal(t, StateClosed, b.State())
+		s, f := b.Counts()
+		assert.Equal(t, 0, s+f, "关闭后重新统计")
+
+		assert.Equal(t, []string{
+			"closed->open", "open->half-open", "half-open->open",
+			"open->half-open", "half-open->closed",
+		}, changes)
+	})
+
+	t.Run("滚动窗口", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		b := NewBreaker(BreakerConfig{Window: 10 * time.Second, Buckets: 10, MinRequests: 4, Clock: clock})
+
+		for i := 0; i < 3; i++ {
+			_ = call(b, errDown)
+		}
+		s, f := b.Counts()
+		assert.Equal(t, 0, s)
+		assert.Equal(t, 3, f)
+
+		// 10s 后前面的失败滑出窗口，再失败一次也达不到 MinRequests
+		clock.Advance(10 * time.Second)
+		_ = call(b, errDown)
+		_, f = b.Counts()
+		assert.Equal(t, 1, f)
+		assert.Equal(t, StateClosed, b.State())
+
+		// 成功拉低失败率
+		clock.Advance(time.Second)
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, call(b, nil))
+		}
+		_ = call(b, errDown)
+		assert.Equal(t, StateClosed, b.State(), "失败率 2/6 低于阈值")
+	})
+
+	t.Run("桶数多于窗口纳秒数", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		b := NewBreaker(BreakerConfig{Window: 5, Buckets: 10, MinRequests: 2, Clock: clock})
+		_ = call(b, errDown)
+		_ = call(b, errDown)
+		assert.Equal(t, StateOpen, b.State())
+
+		b = NewBreaker(BreakerConfig{Window: 5, Buckets: 10, MinRequests: 2, Clock: clock})
+		_ = call(b, errDown)
+		clock.Advance(5)
+		_ = call(b, errDown)
+		assert.Equal(t, StateClosed, b.State(), "第一次失败已经滑出 5ns 的窗口")
+	})
+
+	t.Run("半开探测数", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		b := NewBreaker(BreakerConfig{MinRequests: 1, HalfOpenProbes: 2, OpenTimeout: time.Second, Clock: clock})
+		_ = call(b, errDown)
+		clock.Advance(time.Second)
+
+		done1, err := b.Allow()
+		assert.NoError(t, err)
+		done2, err := b.Allow()
+		assert.NoError(t, err)
+		_, err = b.Allow()
+		assert.ErrorIs(t, err, ErrTooManyProbes)
+
+		done1(nil)
+		assert.Equal(t, StateHalfOpen, b.State(), "两个探测都成功才关闭")
+		done1(errDown) // 重复调用无效
+		done2(nil)
+		assert.Equal(t, StateClosed, b.State())
+	})
+
+	t.Run("ctx取消不计失败", func(t *testing.T) {
+		b := NewBreaker(BreakerConfig{MinRequests: 1, Clock: timesnippet.NewFakeClock(clockStart)})
+		_ = call(b, context.Canceled)
+		assert.Equal(t, StateClosed, b.State())
+	})
+
+	t.Run("并发安全", func(t *testing.T) {
+		b := NewBreaker(BreakerConfig{MinRequests: 10})
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var err error
+				if i%2 == 0 {
+					err = errDown
+				}
+				_ = call(b, err)
+			}(i)
+		}
+		wg.Wait()
+		_ = b.State()
+	})
+}
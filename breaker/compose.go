@@ -0,0 +1,67 @@
+package breakersnippet
+
+import "context"
+
+/*
+把限流、熔断、重试叠在一次调用外面：
+
+	call := Chain(
+		WithRetry(retrier),   // 最外层：每次重试都重新经过熔断和限流
+		WithBreaker(breaker), // 熔断打开时直接失败，ErrOpen 不会被重试
+		WithLimiter(limiter), // *rate.Limiter 满足 Waiter
+	)(func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	})
+	err := call(ctx)
+*/
+
+// Op 一次可被保护的调用
+type Op func(ctx context.Context) error
+
+// Middleware 包装 Op
+type Middleware func(next Op) Op
+
+// Chain 组合多个 Middleware，第一个在最外层
+func Chain(mws ...Middleware) Middleware {
+	return func(next Op) Op {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// Waiter 等待直到允许执行或 ctx 结束，*rate.Limiter 满足此接口
+type Waiter interface {
+	Wait(ctx context.Context) error
+}
+
+// WithLimiter 调用前先经过限流
+func WithLimiter(w Waiter) Middleware {
+	return func(next Op) Op {
+		return func(ctx context.Context) error {
+			if err := w.Wait(ctx); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// WithBreaker 在熔断器保护下调用
+func WithBreaker(b *Breaker) Middleware {
+	return func(next Op) Op {
+		return func(ctx context.Context) error {
+			return b.Do(ctx, next)
+		}
+	}
+}
+
+// WithRetry 失败时按 Retrier 的配置重试
+func WithRetry(r *Retrier) Middleware {
+	return func(next Op) Op {
+		return func(ctx context.Context) error {
+			return r.Do(ctx, next)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package breakersnippet
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+重试：指数退避 + 抖动 + 重试预算。
+
+- 退避间隔从 BaseDelay 开始指数增长，不超过 MaxDelay
+- 抖动避免大量客户端在同一时刻重试（惊群）：
+  - JitterFull：sleep = random(0, min(MaxDelay, BaseDelay×2^n))
+  - JitterDecorrelated：sleep = min(MaxDelay, random(BaseDelay, 上次 sleep×3))
+- RetryBudget 限制重试占总请求的比例，下游整体故障时避免重试把流量放大几倍
+- 等待期间 ctx 结束立即返回；熔断器打开（ErrOpen）不重试
+- 等待用注入的 Clock 计时，测试时换成 FakeClock，不用真的睡眠
+*/
+
+// Jitter 抖动策略
+type Jitter int
+
+const (
+	JitterFull Jitter = iota
+	JitterDecorrelated
+	JitterNone
+)
+
+// RetryConfig 重试配置，零值字段使用默认值
+type RetryConfig struct {
+	MaxAttempts int                  // 最多尝试次数（含第一次），默认 3
+	BaseDelay   time.Duration        // 默认 100ms
+	MaxDelay    time.Duration        // 默认 10s
+	Jitter      Jitter               // 默认 JitterFull
+	Budget      *RetryBudget         // 为空表示不限制
+	Retryable   func(err error) bool // 默认除 ctx 错误、ErrOpen、ErrTooManyProbes 以外都重试
+	Source      rand.Source          // 抖动用的随机源，测试时传入固定种子
+	Clock       timesnippet.Clock    // 为空时使用 timesnippet.RealClock
+}
+
+// Retrier 按配置重试，并发安全
+type Retrier struct {
+	cfg RetryConfig
+
+	mu   sync.Mutex // 保护 rand，*rand.Rand 非并发安全
+	rand *rand.Rand
+}
+
+// NewRetrier 创建重试器
+func NewRetrier(cfg RetryConfig) *Retrier {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 100 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 10 * time.Second
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+				!errors.Is(err, ErrOpen) && !errors.Is(err, ErrTooManyProbes)
+		}
+	}
+	if cfg.Source == nil {
+		cfg.Source = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	return &Retrier{cfg: cfg, rand: rand.New(cfg.Source)}
+}
+
+// sleep 等待 d 或 ctx 结束
+func (r *Retrier) sleep(ctx context.Context, d time.Duration) error {
+	t := r.cfg.Clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
+
+// ErrBudgetExhausted 重试预算用完，返回的错误同时包装了最后一次调用的错误
+var ErrBudgetExhausted = errors.New("breakersnippet: retry budget exhausted")
+
+// Do 执行 op，失败时按配置重试，返回最后一次的错误
+func (r *Retrier) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	if r.cfg.Budget != nil {
+		r.cfg.Budget.deposit()
+	}
+
+	var prev time.Duration
+	for attempt := 0; ; attempt++ {
+		err := op(ctx)
+		if err == nil || attempt+1 >= r.cfg.MaxAttempts || !r.cfg.Retryable(err) {
+			return err
+		}
+		if r.cfg.Budget != nil && !r.cfg.Budget.withdraw() {
+			return errors.Join(ErrBudgetExhausted, err)
+		}
+
+		prev = r.backoff(attempt, prev)
+		if sleepErr := r.sleep(ctx, prev); sleepErr != nil {
+			return errors.Join(sleepErr, err)
+		}
+	}
+}
+
+// backoff 第 attempt 次失败后的等待时间（attempt 从 0 开始）
+func (r *Retrier) backoff(attempt int, prev time.Duration) time.Duration {
+	ceiling := r.cfg.MaxDelay
+	if attempt < 62 {
+		if exp := r.cfg.BaseDelay << attempt; exp > 0 && exp < ceiling {
+			ceiling = exp
+		}
+	}
+
+	switch r.cfg.Jitter {
+	case JitterNone:
+		return ceiling
+	case JitterDecorrelated:
+		if prev <= 0 {
+			prev = r.cfg.BaseDelay
+		}
+		upper := min(prev*3, r.cfg.MaxDelay)
+		if upper <= r.cfg.BaseDelay {
+			return r.cfg.BaseDelay
+		}
+		return r.cfg.BaseDelay + r.randDuration(upper-r.cfg.BaseDelay)
+	default:
+		return r.randDuration(ceiling)
+	}
+}
+
+func (r *Retrier) randDuration(n time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(r.rand.Int64N(int64(n)))
+}
+
+// RetryBudget 重试预算（参考 gRPC retry throttling）：每个请求存入 Ratio 个令牌，每次重试取走 1 个，
+// 令牌不足 1 时不再重试。Ratio=0.1 意味着长期来看重试最多占请求数的 10%
+type RetryBudget struct {
+	ratio     float64
+	maxTokens float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewRetryBudget 初始令牌为 maxTokens，允许冷启动时少量重试
+func NewRetryBudget(ratio, maxTokens float64) *RetryBudget {
+	return &RetryBudget{ratio: ratio, maxTokens: maxTokens, tokens: maxTokens}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	b.tokens = min(b.tokens+b.ratio, b.maxTokens)
+	b.mu.Unlock()
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Tokens 当前剩余令牌
+func (b *RetryBudget) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens
+}
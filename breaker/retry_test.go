@@ -0,0 +1,183 @@
+package breakersnippet
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// autoClock 每创建一个定时器就把 FakeClock 推进到它到期，并记下等待时长，
+// Do 因此可以在测试的 goroutine 里同步跑完
+type autoClock struct {
+	*timesnippet.FakeClock
+	delays []time.Duration
+}
+
+func newAutoClock() *autoClock {
+	return &autoClock{FakeClock: timesnippet.NewFakeClock(clockStart)}
+}
+
+func (c *autoClock) NewTimer(d time.Duration) timesnippet.Timer {
+	t := c.FakeClock.NewTimer(d)
+	c.delays = append(c.delays, d)
+	c.Advance(d)
+	return t
+}
+
+func failN(n int, calls *int) Op {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= n {
+			return errDown
+		}
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("失败后重试直到成功", func(t *testing.T) {
+		clock := newAutoClock()
+		r := NewRetrier(RetryConfig{MaxAttempts: 5, Jitter: JitterNone, Clock: clock})
+		calls := 0
+		assert.NoError(t, r.Do(context.Background(), failN(3, &calls)))
+		assert.Equal(t, 4, calls)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}, clock.delays)
+		assert.Equal(t, clockStart.Add(700*time.Millisecond), clock.Now())
+	})
+
+	t.Run("超过次数返回最后的错误", func(t *testing.T) {
+		r := NewRetrier(RetryConfig{Clock: newAutoClock()})
+		calls := 0
+		assert.ErrorIs(t, r.Do(context.Background(), failN(10, &calls)), errDown)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("全抖动范围", func(t *testing.T) {
+		clock := newAutoClock()
+		r := NewRetrier(RetryConfig{
+			MaxAttempts: 12, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second,
+			Jitter: JitterFull, Source: rand.NewPCG(1, 2), Clock: clock,
+		})
+		calls := 0
+		_ = r.Do(context.Background(), failN(100, &calls))
+		assert.Len(t, clock.delays, 11)
+		for i, d := range clock.delays {
+			ceiling := min(10*time.Millisecond<<i, time.Second)
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.Less(t, d, ceiling)
+		}
+	})
+
+	t.Run("去相关抖动范围", func(t *testing.T) {
+		clock := newAutoClock()
+		base, maxDelay := 10*time.Millisecond, 500*time.Millisecond
+		r := NewRetrier(RetryConfig{
+			MaxAttempts: 20, BaseDelay: base, MaxDelay: maxDelay,
+			Jitter: JitterDecorrelated, Source: rand.NewPCG(1, 2), Clock: clock,
+		})
+		calls := 0
+		_ = r.Do(context.Background(), failN(100, &calls))
+		assert.Len(t, clock.delays, 19)
+		prev := base
+		for _, d := range clock.delays {
+			assert.GreaterOrEqual(t, d, base)
+			assert.LessOrEqual(t, d, min(prev*3, maxDelay))
+			prev = d
+		}
+	})
+
+	t.Run("重试预算", func(t *testing.T) {
+		budget := NewRetryBudget(0.1, 2)
+		r := NewRetrier(RetryConfig{MaxAttempts: 10, Budget: budget, Clock: newAutoClock()})
+		calls := 0
+		err := r.Do(context.Background(), failN(100, &calls))
+		assert.ErrorIs(t, err, ErrBudgetExhausted)
+		assert.ErrorIs(t, err, errDown)
+		assert.Equal(t, 3, calls, "初始 2 个令牌，只能重试 2 次")
+
+		// 之后每 10 个请求才攒够一次重试
+		calls = 0
+		for i := 0; i < 10; i++ {
+			_ = r.Do(context.Background(), func(context.Context) error { return nil })
+		}
+		_ = r.Do(context.Background(), failN(100, &calls))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("ctx取消停止等待", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		r := NewRetrier(RetryConfig{MaxAttempts: 5, BaseDelay: time.Hour, Jitter: JitterNone, Clock: clock})
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		errc := make(chan error, 1)
+		go func() { errc <- r.Do(ctx, failN(100, &calls)) }()
+
+		clock.BlockUntil(1) // 第一次失败后开始退避
+		cancel()
+		err := <-errc
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, errDown)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("不可重试的错误", func(t *testing.T) {
+		r := NewRetrier(RetryConfig{Retryable: func(err error) bool { return !errors.Is(err, errDown) }})
+		calls := 0
+		assert.ErrorIs(t, r.Do(context.Background(), failN(100, &calls)), errDown)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestChain(t *testing.T) {
+	t.Run("熔断打开后不再重试", func(t *testing.T) {
+		clock := newAutoClock()
+		b := NewBreaker(BreakerConfig{MinRequests: 2, Clock: clock})
+		r := NewRetrier(RetryConfig{MaxAttempts: 5, Clock: clock})
+		lim := rate.NewLimiter(rate.Inf, 1)
+
+		calls := 0
+		op := Chain(WithRetry(r), WithBreaker(b), WithLimiter(lim))(failN(100, &calls))
+		err := op(context.Background())
+		assert.ErrorIs(t, err, ErrOpen)
+		assert.Equal(t, 2, calls, "第 2 次失败后熔断，第 3 次尝试直接被拒绝")
+		assert.Len(t, clock.delays, 2)
+	})
+
+	t.Run("限流等待受ctx控制", func(t *testing.T) {
+		lim := rate.NewLimiter(rate.Every(time.Hour), 1)
+		lim.Allow()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		calls := 0
+		err := Chain(WithLimiter(lim))(failN(0, &calls))(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("保护redis调用", func(t *testing.T) {
+		// 连一个没有服务的端口，不需要真实的 redis
+		rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+		defer rdb.Close()
+
+		b := NewBreaker(BreakerConfig{MinRequests: 3, OpenTimeout: time.Minute})
+		r := NewRetrier(RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond})
+		ping := Chain(WithBreaker(b), WithLimiter(rate.NewLimiter(100, 10)), WithRetry(r))(func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		})
+
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			assert.Error(t, ping(ctx))
+		}
+		assert.Equal(t, StateOpen, b.State())
+		assert.ErrorIs(t, ping(ctx), ErrOpen)
+	})
+}
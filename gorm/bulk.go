@@ -0,0 +1,316 @@
+package gormsnippet
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/panjf2000/ants/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+/*
+BulkLoader 批量导入：db.Create(&user) 一次只插一行，几万行要几万次往返。
+
+1. 输入按 ChunkSize 切块，每块一条多行 INSERT
+2. 冲突时更新：gorm 的 clause.OnConflict 在 MySQL 下生成 ON DUPLICATE KEY UPDATE，在 SQLite/PG 下生成 ON CONFLICT ... DO UPDATE；
+   与 ModelPlugin 配合时不覆盖 created_at/created_by，version 在原值上 +1 而不是被新行的 1 覆盖
+3. 各块通过 ants 协程池并行执行
+4. 某一块整体失败时逐行重试，定位出具体失败的行，其余行照常写入
+5. 输入可以是切片、CSV（首行为表头，字段名或列名均可）或 JSON Lines 文件
+*/
+
+const (
+	defaultChunkSize = 500
+	defaultWorkers   = 4
+)
+
+// RowError 单行失败信息
+type RowError struct {
+	Row int // 文件输入时为行号（从 1 开始，CSV 表头为第 1 行，跨行的记录取起始行）；切片输入时为下标
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// BulkResult 导入结果
+type BulkResult struct {
+	Total        int        // 输入行数（含解析失败的行）
+	RowsAffected int64      // 数据库返回的影响行数，MySQL 下更新的行计为 2
+	Failed       []RowError // 按 Row 排序
+}
+
+// BulkLoader 批量导入器，T 为 gorm 模型
+type BulkLoader[T any] struct {
+	DB        *gorm.DB
+	ChunkSize int // 每条 INSERT 的行数，为 0 时使用 500
+	Workers   int // 并行写入的协程数，为 0 时使用 4
+	// ConflictColumns 冲突判定列，为空时使用主键；MySQL 会忽略它，按任意唯一键判定
+	ConflictColumns []string
+	// UpdateColumns 冲突时更新的列，为空时更新除主键、CreatedAt、Audit.CreatedBy、Versioned.Version 以外的全部列；
+	// 模型嵌入 Versioned 时总是额外更新 version = version + 1
+	UpdateColumns []string
+}
+
+// Load 导入切片
+func (l *BulkLoader[T]) Load(ctx context.Context, rows []T) (*BulkResult, error) {
+	lines := make([]int, len(rows))
+	for i := range lines {
+		lines[i] = i
+	}
+	return l.load(ctx, rows, lines, nil)
+}
+
+// LoadFile 按扩展名导入 .csv 或 .jsonl/.ndjson 文件
+func (l *BulkLoader[T]) LoadFile(ctx context.Context, path string) (*BulkResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return l.LoadCSV(ctx, f)
+	case ".jsonl", ".ndjson":
+		return l.LoadJSONLines(ctx, f)
+	default:
+		return nil, fmt.Errorf("gormsnippet: unsupported bulk file %q", path)
+	}
+}
+
+// LoadJSONLines 每行一个 JSON 对象，空行跳过
+func (l *BulkLoader[T]) LoadJSONLines(ctx context.Context, r io.Reader) (*BulkResult, error) {
+	var (
+		rows    []T
+		lines   []int
+		parseEs []RowError
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			parseEs = append(parseEs, RowError{Row: line, Err: err})
+			continue
+		}
+		rows = append(rows, v)
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return l.load(ctx, rows, lines, parseEs)
+}
+
+// LoadCSV 首行为表头，表头可以是字段名（Email）或列名（email）
+func (l *BulkLoader[T]) LoadCSV(ctx context.Context, r io.Reader) (*BulkResult, error) {
+	sch, err := l.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("gormsnippet: read csv header: %w", err)
+	}
+	fields := make([]*schema.Field, len(header))
+	for i, h := range header {
+		if fields[i] = sch.LookUpField(strings.TrimSpace(h)); fields[i] == nil {
+			return nil, fmt.Errorf("gormsnippet: unknown csv column %q", h)
+		}
+	}
+
+	var (
+		rows    []T
+		lines   []int
+		parseEs []RowError
+	)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return nil, err
+			}
+			parseEs = append(parseEs, RowError{Row: pe.StartLine, Err: err})
+			continue
+		}
+		// 引号内的字段可以跨行，行号取记录第一个字段所在的行
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			parseEs = append(parseEs, RowError{Row: line, Err: fmt.Errorf("expected %d fields, got %d", len(header), len(record))})
+			continue
+		}
+
+		var v T
+		rv := reflect.ValueOf(&v).Elem()
+		var rowErr error
+		for i, s := range record {
+			if s == "" {
+				continue
+			}
+			if err := fields[i].Set(ctx, rv, s); err != nil {
+				rowErr = fmt.Errorf("column %s: %w", header[i], err)
+				break
+			}
+		}
+		if rowErr != nil {
+			parseEs = append(parseEs, RowError{Row: line, Err: rowErr})
+			continue
+		}
+		rows = append(rows, v)
+		lines = append(lines, line)
+	}
+	return l.load(ctx, rows, lines, parseEs)
+}
+
+func (l *BulkLoader[T]) load(ctx context.Context, rows []T, lines []int, failed []RowError) (*BulkResult, error) {
+	chunkSize, workers := l.ChunkSize, l.Workers
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	sch, err := l.schema()
+	if err != nil {
+		return nil, err
+	}
+	oc := l.onConflict(sch)
+
+	pool, err := ants.NewPool(workers)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Release()
+
+	res := &BulkResult{Total: len(rows) + len(failed), Failed: failed}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for start := 0; start < len(rows); start += chunkSize {
+		end := min(start+chunkSize, len(rows))
+		chunk, chunkLines := rows[start:end], lines[start:end]
+
+		wg.Add(1)
+		err := pool.Submit(func() {
+			defer wg.Done()
+			affected, errs := l.insertChunk(ctx, oc, chunk, chunkLines)
+			mu.Lock()
+			res.RowsAffected += affected
+			res.Failed = append(res.Failed, errs...)
+			mu.Unlock()
+		})
+		if err != nil {
+			wg.Done()
+			mu.Lock()
+			for _, line := range chunkLines {
+				res.Failed = append(res.Failed, RowError{Row: line, Err: err})
+			}
+			mu.Unlock()
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(res.Failed, func(i, j int) bool { return res.Failed[i].Row < res.Failed[j].Row })
+	return res, ctx.Err()
+}
+
+// insertChunk 先整块写入，失败后逐行写入以定位坏行
+func (l *BulkLoader[T]) insertChunk(ctx context.Context, oc clause.OnConflict, chunk []T, lines []int) (int64, []RowError) {
+	if err := ctx.Err(); err != nil {
+		errs := make([]RowError, len(lines))
+		for i, line := range lines {
+			errs[i] = RowError{Row: line, Err: err}
+		}
+		return 0, errs
+	}
+
+	// Clauses 返回的实例不能复用，否则前一次的错误会累积到下一次
+	db := func() *gorm.DB { return l.DB.WithContext(ctx).Clauses(oc) }
+	tx := db().Create(&chunk)
+	if tx.Error == nil {
+		return tx.RowsAffected, nil
+	}
+
+	var (
+		affected int64
+		errs     []RowError
+	)
+	for i := range chunk {
+		tx := db().Create(&chunk[i])
+		if tx.Error != nil {
+			errs = append(errs, RowError{Row: lines[i], Err: tx.Error})
+			continue
+		}
+		affected += tx.RowsAffected
+	}
+	return affected, errs
+}
+
+func (l *BulkLoader[T]) schema() (*schema.Schema, error) {
+	return schema.Parse(new(T), &sync.Map{}, l.DB.NamingStrategy)
+}
+
+// onConflict 新行里的创建信息和初始版本号不能覆盖已有的行
+func (l *BulkLoader[T]) onConflict(sch *schema.Schema) clause.OnConflict {
+	oc := clause.OnConflict{}
+	for _, c := range l.ConflictColumns {
+		oc.Columns = append(oc.Columns, clause.Column{Name: c})
+	}
+	version := versionField(sch)
+	createdBy := lookUpField(sch, "CreatedBy", reflect.String)
+
+	columns := l.UpdateColumns
+	if len(columns) == 0 {
+		// 与 gorm 的 UpdateAll 取同样的列，再去掉创建信息和版本号
+		for _, f := range sch.Fields {
+			if f.DBName == "" || !f.Creatable || f.PrimaryKey || f.AutoCreateTime > 0 ||
+				f.HasDefaultValue && f.DefaultValueInterface == nil && !strings.EqualFold(f.DefaultValue, "NULL") {
+				continue
+			}
+			if f.Name == "CreatedAt" || f == createdBy || f == version || slices.Contains(l.ConflictColumns, f.DBName) {
+				continue
+			}
+			columns = append(columns, f.DBName)
+		}
+	}
+	if version != nil {
+		columns = slices.DeleteFunc(slices.Clone(columns), func(c string) bool { return c == version.DBName })
+	}
+	oc.DoUpdates = clause.AssignmentColumns(columns)
+	if version != nil {
+		oc.DoUpdates = append(oc.DoUpdates, clause.Assignment{
+			Column: clause.Column{Name: version.DBName},
+			Value:  gorm.Expr("? + 1", clause.Column{Name: version.DBName}),
+		})
+	}
+	return oc
+}
@@ -0,0 +1,160 @@
+package gormsnippet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkLoader(t *testing.T) {
+	t.Run("分块并行写入", func(t *testing.T) {
+		db := openSQLite(t)
+		users := make([]User, 1000)
+		for i := range users {
+			users[i] = User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)}
+		}
+
+		loader := &BulkLoader[User]{DB: db, ChunkSize: 100, Workers: 4}
+		res, err := loader.Load(context.Background(), users)
+		assert.NoError(t, err)
+		assert.Equal(t, 1000, res.Total)
+		assert.Empty(t, res.Failed)
+
+		var count int64
+		db.Model(&User{}).Count(&count)
+		assert.Equal(t, int64(1000), count)
+	})
+
+	t.Run("主键冲突时更新", func(t *testing.T) {
+		db := openSQLite(t)
+		db.Create(&User{ID: 1, Name: "old", Email: "old@example.com"})
+
+		loader := &BulkLoader[User]{DB: db, UpdateColumns: []string{"name"}}
+		_, err := loader.Load(context.Background(), []User{
+			{ID: 1, Name: "new", Email: "new@example.com"},
+			{ID: 2, Name: "other"},
+		})
+		assert.NoError(t, err)
+
+		var got User
+		db.First(&got, 1)
+		assert.Equal(t, "new", got.Name)
+		assert.Equal(t, "old@example.com", got.Email, "只更新 UpdateColumns 中的列")
+	})
+
+	t.Run("冲突更新全部列时保留创建信息并递增版本", func(t *testing.T) {
+		db := openSQLite(t)
+		ctx := WithOperator(context.Background(), "alice")
+		assert.NoError(t, db.WithContext(ctx).Create(&User{ID: 1, Name: "old"}).Error)
+		var before User
+		db.First(&before, 1)
+		assert.NoError(t, db.WithContext(ctx).Model(&before).Update("name", "renamed").Error)
+		assert.Equal(t, int64(2), before.Version)
+
+		loader := &BulkLoader[User]{DB: db}
+		_, err := loader.Load(WithOperator(context.Background(), "bob"), []User{{ID: 1, Name: "new", Email: "new@example.com"}})
+		assert.NoError(t, err)
+
+		var got User
+		db.First(&got, 1)
+		assert.Equal(t, "new", got.Name)
+		assert.Equal(t, "new@example.com", got.Email)
+		assert.Equal(t, "alice", got.CreatedBy, "创建人不被覆盖")
+		assert.Equal(t, "bob", got.UpdatedBy)
+		assert.True(t, before.CreatedAt.Equal(got.CreatedAt), "创建时间不被覆盖")
+		assert.Equal(t, int64(3), got.Version, "版本号 +1 而不是重置为 1")
+	})
+
+	t.Run("业务自己的 Version 字段按普通列更新", func(t *testing.T) {
+		db := openSQLite(t)
+		assert.NoError(t, db.AutoMigrate(&Release{}))
+		assert.NoError(t, db.Create(&Release{ID: 1, Name: "old", Version: "v1.0.0", CreatedBy: 7}).Error)
+
+		loader := &BulkLoader[Release]{DB: db}
+		_, err := loader.Load(context.Background(), []Release{{ID: 1, Name: "new", Version: "v1.1.0", CreatedBy: 8}})
+		assert.NoError(t, err)
+
+		var got Release
+		db.First(&got, 1)
+		assert.Equal(t, Release{ID: 1, Name: "new", Version: "v1.1.0", CreatedBy: 8}, got)
+	})
+
+	t.Run("单行失败不影响同块其他行", func(t *testing.T) {
+		db := openSQLite(t)
+		db.Exec(`CREATE TRIGGER reject_bad BEFORE INSERT ON t_user
+			WHEN NEW.email = 'bad@example.com' BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+
+		loader := &BulkLoader[User]{DB: db, ChunkSize: 10}
+		res, err := loader.Load(context.Background(), []User{
+			{Name: "a", Email: "a@example.com"},
+			{Name: "bad", Email: "bad@example.com"},
+			{Name: "c", Email: "c@example.com"},
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, res.Failed, 1) {
+			assert.Equal(t, 1, res.Failed[0].Row)
+			assert.Contains(t, res.Failed[0].Err.Error(), "rejected")
+		}
+
+		var count int64
+		db.Model(&User{}).Count(&count)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		db := openSQLite(t)
+		csv := "ID,Name,email\n" +
+			"1,\"张\n三\",zhangsan@example.com\n" + // 引号内换行，这条记录占第 2、3 行
+			"abc,李四,lisi@example.com\n" +
+			"3,王五\n" +
+			"4,赵六,zhaoliu@example.com\n" +
+			"5,\"孙\"七\",sunqi@example.com\n"
+
+		loader := &BulkLoader[User]{DB: db}
+		res, err := loader.LoadCSV(context.Background(), strings.NewReader(csv))
+		assert.NoError(t, err)
+		assert.Equal(t, 5, res.Total)
+		if assert.Len(t, res.Failed, 3) {
+			assert.Equal(t, 4, res.Failed[0].Row)
+			assert.Equal(t, 5, res.Failed[1].Row)
+			assert.Equal(t, 7, res.Failed[2].Row)
+		}
+
+		var users []User
+		db.Order("id").Find(&users)
+		if assert.Len(t, users, 2) {
+			assert.Equal(t, "张\n三", users[0].Name)
+			assert.Equal(t, "zhaoliu@example.com", users[1].Email)
+		}
+	})
+
+	t.Run("JSON Lines 文件", func(t *testing.T) {
+		db := openSQLite(t)
+		path := filepath.Join(t.TempDir(), "users.jsonl")
+		content := `{"Name":"张三","Email":"zhangsan@example.com"}
+
+{"Name":"李四"
+{"Name":"王五","Email":"wangwu@example.com"}
+`
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := &BulkLoader[User]{DB: db}
+		res, err := loader.LoadFile(context.Background(), path)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, res.Total)
+		if assert.Len(t, res.Failed, 1) {
+			assert.Equal(t, 3, res.Failed[0].Row)
+		}
+
+		var count int64
+		db.Model(&User{}).Count(&count)
+		assert.Equal(t, int64(2), count)
+	})
+}
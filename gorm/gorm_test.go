@@ -1,9 +1,11 @@
 package gormsnippet
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"testing"
 	"time"
@@ -25,6 +27,9 @@ type User struct {
 	Name      string
 	Email     string
 	CreatedAt time.Time
+	Audit
+	SoftDelete
+	Versioned
 }
 
 // 将User关联到t_user
@@ -40,6 +45,14 @@ func TestGormQuery(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := db.Use(ModelPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	// 以 Debug 级别打印每条 SQL 及耗时，Email 参数脱敏
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if err := db.Use(&QueryLogPlugin{Logger: logger, RedactColumns: []string{"Email"}}); err != nil {
+		t.Fatal(err)
+	}
 
 	// 自动迁移 schema
 	err = db.AutoMigrate(&User{})
@@ -64,8 +77,8 @@ func TestGormQuery(t *testing.T) {
 	// 更新记录
 	db.Model(&result).Update("name", "李四")
 
-	// 删除记录
-	//db.Delete(&result)
+	// 删除记录（软删除，只写 deleted_at）
+	db.Delete(&result)
 }
 
 func TestGormSelect(t *testing.T) {
@@ -83,7 +96,14 @@ func TestGormSelect(t *testing.T) {
 		t.Fatalf("获取底层数据库连接失败: %v", err)
 	}
 
-	if err := sqlDB.Ping(); err != nil {
+	// 应用连接池参数，默认值可被 GORM_SNIPPET_MAX_OPEN_CONNS 等环境变量覆盖
+	poolCfg, err := PoolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("读取连接池配置失败: %v", err)
+	}
+	poolCfg.Apply(sqlDB)
+
+	if err := NewDBHealth(sqlDB, poolCfg).Check(context.Background()); err != nil {
 		t.Fatalf("数据库连接测试失败: %v", err)
 	}
 
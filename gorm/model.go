@@ -0,0 +1,205 @@
+package gormsnippet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+/*
+可复用的模型 mixin，嵌入到业务模型里即可获得对应能力：
+
+1. SoftDelete：软删除。db.Delete 只写 deleted_at，普通查询自动追加 deleted_at IS NULL，
+   需要查已删除数据时用 db.Unscoped()。
+2. Audit：审计字段。UpdatedAt 由 gorm 自动维护；CreatedBy/UpdatedBy 由 ModelPlugin
+   从 context 中的操作人（WithOperator）填充。
+3. Versioned：乐观锁。ModelPlugin 在 UPDATE 时追加 WHERE version = 旧版本，并把 version+1，
+   影响行数为 0 时返回 *VersionConflictError（可用 errors.Is(err, ErrVersionConflict) 判断）。
+
+使用方式：db.Use(ModelPlugin{})，之后所有写操作带上 ctx：db.WithContext(WithOperator(ctx, "alice"))。
+插件按字段名和类型识别 mixin：int64 的 Version、string 的 CreatedBy/UpdatedBy，
+同名但类型不同的字段（例如业务自己的 Version string）不做处理。
+*/
+
+// SoftDelete 软删除 mixin
+type SoftDelete struct {
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// Audit 审计字段 mixin
+type Audit struct {
+	UpdatedAt time.Time
+	CreatedBy string `gorm:"size:64"`
+	UpdatedBy string `gorm:"size:64"`
+}
+
+// Versioned 乐观锁 mixin，新记录从 1 开始
+type Versioned struct {
+	Version int64 `gorm:"not null;default:1"`
+}
+
+// ErrVersionConflict 乐观锁冲突的哨兵错误
+var ErrVersionConflict = errors.New("gormsnippet: optimistic lock version conflict")
+
+// VersionConflictError 记录冲突发生时的表名、主键和调用方持有的版本号
+type VersionConflictError struct {
+	Table   string
+	ID      interface{}
+	Version int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("gormsnippet: %s id=%v version %d is stale", e.Table, e.ID, e.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+type operatorKey struct{}
+
+// WithOperator 把当前操作人放进 context，供审计字段使用
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, operatorKey{}, operator)
+}
+
+// OperatorFromContext 取出 WithOperator 设置的操作人
+func OperatorFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	op, ok := ctx.Value(operatorKey{}).(string)
+	return op, ok && op != ""
+}
+
+const versionSettingKey = "gormsnippet:lock_version"
+
+// lookUpField 按名字查找字段，类型不是 kind 时返回 nil
+func lookUpField(sch *schema.Schema, name string, kind reflect.Kind) *schema.Field {
+	f := sch.LookUpField(name)
+	if f == nil || f.FieldType.Kind() != kind {
+		return nil
+	}
+	return f
+}
+
+// versionField 乐观锁字段，即 Versioned 的 Version int64
+func versionField(sch *schema.Schema) *schema.Field {
+	return lookUpField(sch, "Version", reflect.Int64)
+}
+
+// ModelPlugin 为 Audit 和 Versioned 注册回调
+type ModelPlugin struct{}
+
+func (ModelPlugin) Name() string {
+	return "gormsnippet:model"
+}
+
+func (ModelPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("gormsnippet:model_before_create", beforeCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("gormsnippet:model_before_update", beforeUpdate); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("gormsnippet:model_after_update", afterUpdate)
+}
+
+func beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	op, hasOp := OperatorFromContext(db.Statement.Context)
+	createdBy := lookUpField(db.Statement.Schema, "CreatedBy", reflect.String)
+	updatedBy := lookUpField(db.Statement.Schema, "UpdatedBy", reflect.String)
+	version := versionField(db.Statement.Schema)
+
+	fill := func(rv reflect.Value) {
+		ctx := db.Statement.Context
+		if hasOp {
+			if createdBy != nil {
+				if _, zero := createdBy.ValueOf(ctx, rv); zero {
+					db.AddError(createdBy.Set(ctx, rv, op))
+				}
+			}
+			if updatedBy != nil {
+				if _, zero := updatedBy.ValueOf(ctx, rv); zero {
+					db.AddError(updatedBy.Set(ctx, rv, op))
+				}
+			}
+		}
+		if version != nil {
+			if _, zero := version.ValueOf(ctx, rv); zero {
+				db.AddError(version.Set(ctx, rv, int64(1)))
+			}
+		}
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			fill(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		fill(rv)
+	}
+}
+
+func beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	if op, ok := OperatorFromContext(db.Statement.Context); ok && lookUpField(db.Statement.Schema, "UpdatedBy", reflect.String) != nil {
+		db.Statement.SetColumn("UpdatedBy", op, true)
+	}
+
+	// 乐观锁只作用于单条记录的更新，批量 Where 更新不加版本条件
+	field := versionField(db.Statement.Schema)
+	if field == nil || db.Statement.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	v, zero := field.ValueOf(db.Statement.Context, db.Statement.ReflectValue)
+	if zero {
+		return
+	}
+	current := v.(int64)
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: current},
+	}})
+	db.Statement.SetColumn(field.Name, current+1, true)
+	db.InstanceSet(versionSettingKey, current)
+}
+
+func afterUpdate(db *gorm.DB) {
+	v, ok := db.InstanceGet(versionSettingKey)
+	if !ok {
+		return
+	}
+	current := v.(int64)
+	if db.Error == nil && db.RowsAffected > 0 {
+		return
+	}
+	rollbackVersion(db, current)
+	if db.Error != nil {
+		return
+	}
+
+	var id interface{}
+	if pf := db.Statement.Schema.PrioritizedPrimaryField; pf != nil {
+		id, _ = pf.ValueOf(db.Statement.Context, db.Statement.ReflectValue)
+	}
+	db.AddError(&VersionConflictError{Table: db.Statement.Table, ID: id, Version: current})
+}
+
+// rollbackVersion 更新失败时把模型上的版本号还原，调用方可以据此重新加载再重试
+func rollbackVersion(db *gorm.DB, current int64) {
+	field := versionField(db.Statement.Schema)
+	_ = field.Set(db.Statement.Context, db.Statement.ReflectValue, current)
+}
@@ -0,0 +1,172 @@
+package gormsnippet
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openSQLite 打开一个内存 SQLite，不依赖外部 MySQL，方便在本地直接跑
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开 sqlite 失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// :memory: 每个连接都是一个独立的库，限制为单连接保证看到同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	if err := db.Use(ModelPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSoftDelete(t *testing.T) {
+	db := openSQLite(t)
+
+	user := User{Name: "张三"}
+	assert.NoError(t, db.Create(&user).Error)
+	assert.NoError(t, db.Delete(&user).Error)
+
+	// 普通查询看不到软删除的记录
+	var count int64
+	db.Model(&User{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Unscoped 能查到，并且 deleted_at 有值
+	var deleted User
+	assert.NoError(t, db.Unscoped().First(&deleted, user.ID).Error)
+	assert.True(t, deleted.DeletedAt.Valid)
+}
+
+func TestAuditFields(t *testing.T) {
+	db := openSQLite(t)
+
+	ctx := WithOperator(context.Background(), "alice")
+	user := User{Name: "张三"}
+	assert.NoError(t, db.WithContext(ctx).Create(&user).Error)
+	assert.Equal(t, "alice", user.CreatedBy)
+	assert.Equal(t, "alice", user.UpdatedBy)
+
+	ctx = WithOperator(context.Background(), "bob")
+	assert.NoError(t, db.WithContext(ctx).Model(&user).Update("name", "李四").Error)
+
+	var got User
+	assert.NoError(t, db.First(&got, user.ID).Error)
+	assert.Equal(t, "李四", got.Name)
+	assert.Equal(t, "alice", got.CreatedBy)
+	assert.Equal(t, "bob", got.UpdatedBy)
+	assert.False(t, got.UpdatedAt.IsZero())
+
+	// 批量创建也会填充
+	users := []User{{Name: "a"}, {Name: "b"}}
+	assert.NoError(t, db.WithContext(ctx).Create(&users).Error)
+	for _, u := range users {
+		assert.Equal(t, "bob", u.CreatedBy)
+	}
+}
+
+func TestOptimisticLock(t *testing.T) {
+	db := openSQLite(t)
+
+	user := User{Name: "张三"}
+	assert.NoError(t, db.Create(&user).Error)
+	assert.Equal(t, int64(1), user.Version)
+
+	// 两个调用方读到同一个版本
+	var first, second User
+	db.First(&first, user.ID)
+	db.First(&second, user.ID)
+
+	assert.NoError(t, db.Model(&first).Update("name", "李四").Error)
+	assert.Equal(t, int64(2), first.Version)
+
+	// 第二个调用方持有的是旧版本，更新失败并拿到类型化错误
+	err := db.Model(&second).Update("name", "王五").Error
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+	var conflict *VersionConflictError
+	if assert.True(t, errors.As(err, &conflict)) {
+		assert.Equal(t, "t_user", conflict.Table)
+		assert.Equal(t, int64(1), conflict.Version)
+	}
+	assert.Equal(t, int64(1), second.Version, "冲突后模型上的版本号应还原")
+
+	// Save 走同样的检查
+	second.Name = "王五"
+	assert.True(t, errors.Is(db.Save(&second).Error, ErrVersionConflict))
+
+	// 重新加载后再更新成功
+	db.First(&second, user.ID)
+	assert.NoError(t, db.Model(&second).Update("name", "王五").Error)
+
+	var got User
+	db.First(&got, user.ID)
+	assert.Equal(t, "王五", got.Name)
+	assert.Equal(t, int64(3), got.Version)
+}
+
+// Release 业务自带的 Version/CreatedBy 字段，类型和 mixin 不同，插件应当不碰它们
+type Release struct {
+	ID        uint `gorm:"primarykey"`
+	Name      string
+	Version   string
+	CreatedBy int64
+}
+
+// Counter 整型但不是 int64 的 Version
+type Counter struct {
+	ID      uint `gorm:"primarykey"`
+	Name    string
+	Version int
+}
+
+func TestModelPluginIgnoresOtherFieldTypes(t *testing.T) {
+	db := openSQLite(t)
+	assert.NoError(t, db.AutoMigrate(&Release{}, &Counter{}))
+	ctx := WithOperator(context.Background(), "alice")
+
+	t.Run("Version string", func(t *testing.T) {
+		r := Release{Name: "first", Version: "v1.0.0"}
+		assert.NoError(t, db.WithContext(ctx).Create(&r).Error)
+		assert.Equal(t, int64(0), r.CreatedBy)
+
+		assert.NoError(t, db.WithContext(ctx).Model(&r).Update("name", "second").Error)
+		assert.NoError(t, db.WithContext(ctx).Model(&r).Updates(Release{Version: "v1.1.0"}).Error)
+
+		var got Release
+		assert.NoError(t, db.First(&got, r.ID).Error)
+		assert.Equal(t, "second", got.Name)
+		assert.Equal(t, "v1.1.0", got.Version)
+		assert.Equal(t, int64(0), got.CreatedBy)
+	})
+
+	t.Run("Version int", func(t *testing.T) {
+		c := Counter{Name: "a", Version: 7}
+		assert.NoError(t, db.WithContext(ctx).Create(&c).Error)
+
+		// 没有乐观锁：同一个旧版本的模型可以反复更新，版本号也不会自动递增
+		stale := c
+		assert.NoError(t, db.Model(&c).Update("name", "b").Error)
+		assert.NoError(t, db.Model(&stale).Update("name", "c").Error)
+		assert.Equal(t, 7, c.Version)
+
+		var got Counter
+		assert.NoError(t, db.First(&got, c.ID).Error)
+		assert.Equal(t, "c", got.Name)
+		assert.Equal(t, 7, got.Version)
+	})
+}
@@ -0,0 +1,277 @@
+package gormsnippet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+连接池配置与健康检查。
+
+database/sql 默认 MaxOpenConns 不限、MaxIdleConns=2、连接永不过期，线上很容易出现：
+连接数打满数据库、空闲连接被中间件/防火墙静默断开后第一次请求报错。
+
+- PoolConfig：池参数，可从环境变量读取（与 GORM_SNIPPET_DSN 同一前缀）
+- OpenDB：打开连接、应用池参数并 Ping
+- DBHealth：定期 Ping，提供 sql.DBStats 指标（Prometheus 文本格式）和就绪探针 handler
+*/
+
+// PoolConfig 连接池参数
+type PoolConfig struct {
+	MaxOpenConns        int           // 最大打开连接数，0 表示不限
+	MaxIdleConns        int           // 最大空闲连接数
+	ConnMaxLifetime     time.Duration // 连接最长存活时间，应小于数据库/代理的 wait_timeout
+	ConnMaxIdleTime     time.Duration // 空闲连接最长保留时间
+	HealthCheckInterval time.Duration // 健康检查间隔
+	HealthCheckTimeout  time.Duration // 单次 Ping 超时，池被占满时 Ping 会在这里超时
+}
+
+// DefaultPoolConfig 默认池参数
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:        20,
+		MaxIdleConns:        10,
+		ConnMaxLifetime:     30 * time.Minute,
+		ConnMaxIdleTime:     5 * time.Minute,
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  time.Second,
+	}
+}
+
+// PoolConfigFromEnv 在默认值基础上读取环境变量：
+// GORM_SNIPPET_MAX_OPEN_CONNS、GORM_SNIPPET_MAX_IDLE_CONNS、GORM_SNIPPET_CONN_MAX_LIFETIME、
+// GORM_SNIPPET_CONN_MAX_IDLE_TIME、GORM_SNIPPET_HEALTH_CHECK_INTERVAL、GORM_SNIPPET_HEALTH_CHECK_TIMEOUT
+func PoolConfigFromEnv() (PoolConfig, error) {
+	cfg := DefaultPoolConfig()
+	ints := map[string]*int{
+		"GORM_SNIPPET_MAX_OPEN_CONNS": &cfg.MaxOpenConns,
+		"GORM_SNIPPET_MAX_IDLE_CONNS": &cfg.MaxIdleConns,
+	}
+	for key, dst := range ints {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return cfg, fmt.Errorf("gormsnippet: %s: %w", key, err)
+			}
+			*dst = n
+		}
+	}
+	durations := map[string]*time.Duration{
+		"GORM_SNIPPET_CONN_MAX_LIFETIME":     &cfg.ConnMaxLifetime,
+		"GORM_SNIPPET_CONN_MAX_IDLE_TIME":    &cfg.ConnMaxIdleTime,
+		"GORM_SNIPPET_HEALTH_CHECK_INTERVAL": &cfg.HealthCheckInterval,
+		"GORM_SNIPPET_HEALTH_CHECK_TIMEOUT":  &cfg.HealthCheckTimeout,
+	}
+	for key, dst := range durations {
+		if v := os.Getenv(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return cfg, fmt.Errorf("gormsnippet: %s: %w", key, err)
+			}
+			*dst = d
+		}
+	}
+	return cfg, nil
+}
+
+// Apply 把池参数应用到 sql.DB
+func (c PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// OpenDB 打开 gorm 连接、应用池参数并 Ping 一次
+func OpenDB(dialector gorm.Dialector, cfg PoolConfig, opts ...gorm.Option) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Apply(sqlDB)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout(cfg))
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func pingTimeout(cfg PoolConfig) time.Duration {
+	if cfg.HealthCheckTimeout > 0 {
+		return cfg.HealthCheckTimeout
+	}
+	return time.Second
+}
+
+// PoolMetrics sql.DBStats 的快照，附带健康状态。
+// 逐个字段拷贝而不是内嵌 sql.DBStats：后者没有 json tag，会和下面的字段混出两种命名风格
+type PoolMetrics struct {
+	MaxOpenConnections int           `json:"max_open_connections"`
+	OpenConnections    int           `json:"open_connections"`
+	InUse              int           `json:"in_use"`
+	Idle               int           `json:"idle"`
+	WaitCount          int64         `json:"wait_count"`
+	WaitDuration       time.Duration `json:"wait_duration"` // 纳秒
+	MaxIdleClosed      int64         `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64         `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64         `json:"max_lifetime_closed"`
+
+	Healthy   bool      `json:"healthy"`
+	Exhausted bool      `json:"exhausted"` // 已用连接数达到 MaxOpenConns
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// DBHealth 定期检查数据库连通性并记录连接池状态
+type DBHealth struct {
+	db  *sql.DB
+	cfg PoolConfig
+
+	mu        sync.RWMutex
+	lastCheck time.Time
+	lastErr   error
+	checked   bool
+}
+
+// NewDBHealth 创建健康检查器，Start 之前需要先调用一次 Check 才会变为就绪
+func NewDBHealth(db *sql.DB, cfg PoolConfig) *DBHealth {
+	return &DBHealth{db: db, cfg: cfg}
+}
+
+// Check 执行一次带超时的 Ping
+func (h *DBHealth) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout(h.cfg))
+	defer cancel()
+	err := h.db.PingContext(ctx)
+
+	h.mu.Lock()
+	h.lastCheck, h.lastErr, h.checked = time.Now(), err, true
+	h.mu.Unlock()
+	return err
+}
+
+// Start 立即检查一次，之后每隔 HealthCheckInterval 检查，ctx 取消后退出
+func (h *DBHealth) Start(ctx context.Context) {
+	interval := h.cfg.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultPoolConfig().HealthCheckInterval
+	}
+	_ = h.Check(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = h.Check(ctx)
+			}
+		}
+	}()
+}
+
+// Ready 最近一次检查是否成功
+func (h *DBHealth) Ready() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.checked && h.lastErr == nil
+}
+
+// Metrics 返回当前连接池指标
+func (h *DBHealth) Metrics() PoolMetrics {
+	stats := h.db.Stats()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	m := PoolMetrics{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		Healthy:            h.checked && h.lastErr == nil,
+		Exhausted:          stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections,
+		LastCheck:          h.lastCheck,
+	}
+	if h.lastErr != nil {
+		m.LastError = h.lastErr.Error()
+	}
+	return m
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出指标
+func (h *DBHealth) WriteMetrics(w io.Writer) error {
+	m := h.Metrics()
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"db_pool_max_open_connections", "Maximum number of open connections to the database.", float64(m.MaxOpenConnections)},
+		{"db_pool_open_connections", "The number of established connections both in use and idle.", float64(m.OpenConnections)},
+		{"db_pool_in_use", "The number of connections currently in use.", float64(m.InUse)},
+		{"db_pool_idle", "The number of idle connections.", float64(m.Idle)},
+		{"db_pool_wait_count_total", "The total number of connections waited for.", float64(m.WaitCount)},
+		{"db_pool_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", m.WaitDuration.Seconds()},
+		{"db_pool_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.", float64(m.MaxIdleClosed)},
+		{"db_pool_max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime.", float64(m.MaxIdleTimeClosed)},
+		{"db_pool_max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime.", float64(m.MaxLifetimeClosed)},
+		{"db_pool_exhausted", "Whether all allowed connections are in use (1) or not (0).", boolGauge(m.Exhausted)},
+		{"db_up", "Whether the last health check succeeded (1) or not (0).", boolGauge(m.Healthy)},
+	}
+	for _, g := range gauges {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// MetricsHandler 暴露 /metrics
+func (h *DBHealth) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = h.WriteMetrics(w)
+	})
+}
+
+// ReadinessHandler 就绪探针：实时 Ping 一次，成功返回 200，否则 503，响应体为 PoolMetrics 的 JSON
+func (h *DBHealth) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		if err := h.Check(r.Context()); err != nil {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(h.Metrics())
+	})
+}
@@ -0,0 +1,113 @@
+package gormsnippet
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	t.Setenv("GORM_SNIPPET_MAX_OPEN_CONNS", "50")
+	t.Setenv("GORM_SNIPPET_CONN_MAX_LIFETIME", "10m")
+
+	cfg, err := PoolConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 50, cfg.MaxOpenConns)
+	assert.Equal(t, 10*time.Minute, cfg.ConnMaxLifetime)
+	assert.Equal(t, DefaultPoolConfig().MaxIdleConns, cfg.MaxIdleConns)
+
+	t.Setenv("GORM_SNIPPET_MAX_IDLE_CONNS", "x")
+	_, err = PoolConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestDBHealth(t *testing.T) {
+	cfg := DefaultPoolConfig()
+	cfg.MaxOpenConns = 2
+	cfg.HealthCheckTimeout = 50 * time.Millisecond
+
+	db, err := OpenDB(sqlite.Open(":memory:"), cfg, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	health := NewDBHealth(sqlDB, cfg)
+	ready := httptest.NewServer(health.ReadinessHandler())
+	defer ready.Close()
+
+	t.Run("正常", func(t *testing.T) {
+		resp, err := http.Get(ready.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.True(t, health.Ready())
+		assert.Equal(t, 2, health.Metrics().MaxOpenConnections)
+	})
+
+	t.Run("连接池耗尽", func(t *testing.T) {
+		ctx := context.Background()
+		var conns []*sql.Conn
+		for i := 0; i < cfg.MaxOpenConns; i++ {
+			c, err := sqlDB.Conn(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conns = append(conns, c)
+		}
+
+		// 池被占满，就绪探针里的 Ping 拿不到连接，超时后返回 503
+		resp, err := http.Get(ready.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body PoolMetrics
+		assert.NoError(t, json.Unmarshal(raw, &body))
+		// 连接池字段和健康状态字段统一使用 snake_case
+		var keys map[string]any
+		assert.NoError(t, json.Unmarshal(raw, &keys))
+		assert.Contains(t, keys, "in_use")
+		assert.Contains(t, keys, "wait_count")
+		assert.Contains(t, keys, "max_open_connections")
+		assert.NotContains(t, keys, "InUse")
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.True(t, body.Exhausted)
+		assert.False(t, body.Healthy)
+		assert.Equal(t, 2, body.InUse)
+		assert.GreaterOrEqual(t, body.WaitCount, int64(1))
+		assert.Contains(t, body.LastError, "deadline exceeded")
+
+		var buf bytes.Buffer
+		assert.NoError(t, health.WriteMetrics(&buf))
+		assert.Contains(t, buf.String(), "db_pool_in_use 2\n")
+		assert.Contains(t, buf.String(), "db_pool_exhausted 1\n")
+		assert.Contains(t, buf.String(), "db_up 0\n")
+
+		for _, c := range conns {
+			_ = c.Close()
+		}
+		assert.NoError(t, health.Check(ctx))
+		assert.True(t, health.Ready())
+		assert.False(t, health.Metrics().Exhausted)
+	})
+}
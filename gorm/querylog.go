@@ -0,0 +1,348 @@
+package gormsnippet
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+QueryLogPlugin 记录每条 SQL 的执行情况，解决 db.Where(...).Find 之后看不到“到底发了什么 SQL、花了多久”的问题。
+
+记录内容：SQL（占位符形式 + 代入参数后的可读形式）、绑定参数、耗时、影响行数、调用方代码位置。
+- RedactColumns 中列出的列（字段名 Email 或列名 email 都可以）对应的参数会被替换成 "***"
+- 超过 SlowThreshold 的语句标记为慢查询，以 Warn 级别输出；出错的语句以 Error 级别输出；其余为 Debug
+- 日志通过 log/slog 输出；Sink 用于在测试里拿到结构化记录做断言（见 MemorySink）
+
+用法：db.Use(&QueryLogPlugin{Logger: slog.Default(), SlowThreshold: 100 * time.Millisecond, RedactColumns: []string{"Email"}})
+*/
+
+const (
+	queryStartKey         = "gormsnippet:query_start"
+	defaultSlowThreshold  = 200 * time.Millisecond
+	redactedPlaceholder   = "***"
+	queryLogCallbackStart = "gormsnippet:querylog_start"
+	queryLogCallbackEnd   = "gormsnippet:querylog_end"
+)
+
+// QueryRecord 一条 SQL 的执行记录
+type QueryRecord struct {
+	Operation string        // create / query / update / delete / row / raw
+	Table     string        // 表名，Raw SQL 可能为空
+	SQL       string        // 带占位符的 SQL
+	Vars      []interface{} // 绑定参数，敏感列已脱敏
+	Explained string        // 代入（脱敏后）参数的 SQL，便于直接阅读
+	Duration  time.Duration
+	Rows      int64
+	Caller    string // file:line
+	Slow      bool
+	Err       error
+}
+
+// QuerySink 接收执行记录
+type QuerySink interface {
+	Record(QueryRecord)
+}
+
+// MemorySink 把记录保存在内存里，供测试断言
+type MemorySink struct {
+	mu      sync.Mutex
+	records []QueryRecord
+}
+
+func (s *MemorySink) Record(r QueryRecord) {
+	s.mu.Lock()
+	s.records = append(s.records, r)
+	s.mu.Unlock()
+}
+
+// Records 返回记录的副本
+func (s *MemorySink) Records() []QueryRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]QueryRecord(nil), s.records...)
+}
+
+// Reset 清空记录
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	s.records = nil
+	s.mu.Unlock()
+}
+
+// QueryLogPlugin 查询日志 + 慢查询插件
+type QueryLogPlugin struct {
+	Logger        *slog.Logger  // 为空时使用 slog.Default()
+	SlowThreshold time.Duration // 为 0 时使用 200ms
+	RedactColumns []string      // 需要脱敏的字段名或列名
+	Sink          QuerySink     // 可选
+
+	redact map[string]struct{}
+}
+
+func (p *QueryLogPlugin) Name() string {
+	return "gormsnippet:querylog"
+}
+
+func (p *QueryLogPlugin) Initialize(db *gorm.DB) error {
+	if p.Logger == nil {
+		p.Logger = slog.Default()
+	}
+	if p.SlowThreshold <= 0 {
+		p.SlowThreshold = defaultSlowThreshold
+	}
+	p.redact = make(map[string]struct{}, len(p.RedactColumns)*2)
+	for _, c := range p.RedactColumns {
+		p.redact[strings.ToLower(c)] = struct{}{}
+		p.redact[strings.ToLower(db.NamingStrategy.ColumnName("", c))] = struct{}{}
+	}
+
+	cb := db.Callback()
+	type hook struct {
+		op     string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}
+	hooks := []hook{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before(queryLogCallbackStart, p.start); err != nil {
+			return err
+		}
+		if err := h.after(queryLogCallbackEnd, p.end(h.op)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *QueryLogPlugin) start(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func (p *QueryLogPlugin) end(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		stmt := db.Statement
+		sql := stmt.SQL.String()
+		if sql == "" {
+			return
+		}
+
+		vars := p.redactVars(sql, stmt.Vars)
+		r := QueryRecord{
+			Operation: op,
+			Table:     stmt.Table,
+			SQL:       sql,
+			Vars:      vars,
+			Explained: db.Dialector.Explain(sql, vars...),
+			Duration:  time.Since(v.(time.Time)),
+			Rows:      db.RowsAffected,
+			Caller:    callerLocation(),
+		}
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			r.Err = db.Error
+		}
+		r.Slow = r.Duration >= p.SlowThreshold
+
+		if p.Sink != nil {
+			p.Sink.Record(r)
+		}
+		p.log(stmt.Context, r)
+	}
+}
+
+func (p *QueryLogPlugin) log(ctx context.Context, r QueryRecord) {
+	level, msg := slog.LevelDebug, "gorm query"
+	switch {
+	case r.Err != nil:
+		level, msg = slog.LevelError, "gorm query failed"
+	case r.Slow:
+		level, msg = slog.LevelWarn, "gorm slow query"
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !p.Logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("op", r.Operation),
+		slog.String("table", r.Table),
+		slog.String("sql", r.Explained),
+		slog.Duration("duration", r.Duration),
+		slog.Int64("rows", r.Rows),
+		slog.String("caller", r.Caller),
+		slog.Bool("slow", r.Slow),
+	}
+	if r.Err != nil {
+		attrs = append(attrs, slog.String("error", r.Err.Error()))
+	}
+	p.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// redactVars 按占位符对应的列名脱敏，返回新切片，不修改 Statement.Vars
+func (p *QueryLogPlugin) redactVars(sql string, vars []interface{}) []interface{} {
+	out := append([]interface{}(nil), vars...)
+	if len(p.redact) == 0 {
+		return out
+	}
+	for i, col := range placeholderColumns(sql) {
+		if i >= len(out) {
+			break
+		}
+		if _, ok := p.redact[col]; ok {
+			out[i] = redactedPlaceholder
+		}
+	}
+	return out
+}
+
+var querylogFile string
+
+func init() {
+	_, querylogFile, _, _ = runtime.Caller(0)
+}
+
+// callerLocation 找到第一个不在 gorm 源码和本插件里的调用栈帧
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.File != querylogFile && !strings.Contains(frame.File, "/gorm.io/") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// sqlKeywords 出现在占位符之前、但不是列名的关键字
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "LIKE": true, "BETWEEN": true, "IS": true, "NULL": true, "SET": true,
+	"VALUES": true, "INSERT": true, "INTO": true, "UPDATE": true, "DELETE": true,
+	"LIMIT": true, "OFFSET": true, "ORDER": true, "BY": true, "GROUP": true, "HAVING": true,
+	"ON": true, "AS": true, "ASC": true, "DESC": true, "RETURNING": true, "DUPLICATE": true,
+	"KEY": true, "CONFLICT": true, "DO": true, "JOIN": true, "LEFT": true, "INNER": true,
+}
+
+// placeholderColumns 粗略解析 SQL，返回每个 ? 占位符绑定的列名（小写、去引号、去表名），无法判断时为空串。
+//
+// 只需要覆盖 gorm 生成的语句形态：
+//   - INSERT INTO t (`a`,`b`) VALUES (?,?),(?,?)：按元组内的位置对应列
+//   - `a` = ? / `a` IN (?,?) / `a` LIKE ? / SET `a`=?：取操作符前最近的列名
+//   - LIMIT ? / OFFSET ?：不对应任何列
+func placeholderColumns(sql string) []string {
+	var (
+		cols       []string
+		insertCols []string
+		inInsert   bool // 正在读取 INSERT 的列清单
+		inValues   bool // 已进入 VALUES 部分
+		depth      int
+		tupleIdx   int
+		candidate  string // 最近出现的列名
+		bound      string // 最近一个比较操作符左侧的列名
+	)
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			// 字符串字面量，跳过
+			i++
+			for i < len(sql) && sql[i] != '\'' {
+				i++
+			}
+			i++
+		case c == '`' || c == '"':
+			j := strings.IndexByte(sql[i+1:], c)
+			if j < 0 {
+				return cols
+			}
+			ident := strings.ToLower(sql[i+1 : i+1+j])
+			candidate = ident
+			if inInsert && depth == 1 {
+				insertCols = append(insertCols, ident)
+			}
+			i += j + 2
+		case isIdentByte(c):
+			j := i
+			for j < len(sql) && isIdentByte(sql[j]) {
+				j++
+			}
+			word := sql[i:j]
+			upper := strings.ToUpper(word)
+			switch {
+			case upper == "INSERT":
+				inInsert = true
+			case upper == "VALUES" && depth == 0:
+				inInsert, inValues = false, true
+			case upper == "ON" || upper == "RETURNING":
+				inValues = false
+			case upper == "LIMIT" || upper == "OFFSET":
+				bound, candidate = "", ""
+			case upper == "LIKE" || upper == "IN" || upper == "BETWEEN" || upper == "IS":
+				bound = candidate
+			case !sqlKeywords[upper]:
+				candidate = strings.ToLower(word)
+				if inInsert && depth == 1 {
+					insertCols = append(insertCols, candidate)
+				}
+			}
+			i = j
+		case c == '(':
+			depth++
+			if inValues && depth == 1 {
+				tupleIdx = 0
+			}
+			i++
+		case c == ')':
+			depth--
+			if inInsert && depth == 0 && len(insertCols) > 0 {
+				inInsert = false
+			}
+			i++
+		case c == ',':
+			if inValues && depth == 1 {
+				tupleIdx++
+			}
+			i++
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			bound = candidate
+			i++
+		case c == '?':
+			col := bound
+			if inValues && depth == 1 && tupleIdx < len(insertCols) {
+				col = insertCols[tupleIdx]
+			}
+			cols = append(cols, col)
+			i++
+		default:
+			i++
+		}
+	}
+	return cols
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,115 @@
+package gormsnippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholderColumns(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want []string
+	}{
+		{
+			sql:  "SELECT * FROM `t_user` WHERE email = ? AND `t_user`.`deleted_at` IS NULL LIMIT ?",
+			want: []string{"email", ""},
+		},
+		{
+			sql:  "INSERT INTO `t_user` (`name`,`email`,`created_at`) VALUES (?,?,?),(?,?,?)",
+			want: []string{"name", "email", "created_at", "name", "email", "created_at"},
+		},
+		{
+			sql:  "UPDATE `t_user` SET `email`=?,`updated_at`=? WHERE `id` IN (?,?)",
+			want: []string{"email", "updated_at", "id", "id"},
+		},
+		{
+			sql:  `SELECT * FROM "t_user" WHERE "t_user"."email" LIKE ? AND name <> 'a = ?'`,
+			want: []string{"email"},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, placeholderColumns(c.sql), c.sql)
+	}
+}
+
+func TestQueryLogPlugin(t *testing.T) {
+	db := openSQLite(t)
+
+	var buf bytes.Buffer
+	sink := &MemorySink{}
+	plugin := &QueryLogPlugin{
+		Logger:        slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		SlowThreshold: time.Hour,
+		RedactColumns: []string{"Email"},
+		Sink:          sink,
+	}
+	if err := db.Use(plugin); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("记录SQL并脱敏", func(t *testing.T) {
+		sink.Reset()
+		db.Create(&User{Name: "张三", Email: "zhangsan@example.com"})
+		var users []User
+		db.Where("email = ?", "zhangsan@example.com").Find(&users)
+
+		records := sink.Records()
+		if !assert.Len(t, records, 2) {
+			return
+		}
+
+		insert := records[0]
+		assert.Equal(t, "create", insert.Operation)
+		assert.Equal(t, "t_user", insert.Table)
+		assert.Equal(t, int64(1), insert.Rows)
+		assert.Contains(t, insert.Vars, "张三")
+		assert.Contains(t, insert.Vars, redactedPlaceholder)
+		assert.NotContains(t, insert.Explained, "zhangsan@example.com")
+
+		query := records[1]
+		assert.Equal(t, "query", query.Operation)
+		assert.Equal(t, []interface{}{redactedPlaceholder}, query.Vars)
+		assert.Equal(t, int64(1), query.Rows)
+		assert.False(t, query.Slow)
+		assert.True(t, strings.Contains(query.Caller, "querylog_test.go:"), query.Caller)
+
+		assert.NotContains(t, buf.String(), "zhangsan@example.com")
+	})
+
+	t.Run("慢查询", func(t *testing.T) {
+		sink.Reset()
+		buf.Reset()
+		plugin.SlowThreshold = time.Nanosecond
+		defer func() { plugin.SlowThreshold = time.Hour }()
+
+		var count int64
+		db.Model(&User{}).Count(&count)
+
+		records := sink.Records()
+		if assert.Len(t, records, 1) {
+			assert.True(t, records[0].Slow)
+		}
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "WARN", line["level"])
+		assert.Equal(t, "gorm slow query", line["msg"])
+		assert.Equal(t, true, line["slow"])
+	})
+
+	t.Run("错误", func(t *testing.T) {
+		sink.Reset()
+		db.Exec("SELECT * FROM not_exists")
+
+		records := sink.Records()
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "raw", records[0].Operation)
+			assert.Error(t, records[0].Err)
+		}
+	})
+}
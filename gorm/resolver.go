@@ -0,0 +1,223 @@
+package gormsnippet
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallnest/weighted"
+	"gorm.io/gorm"
+)
+
+/*
+Resolver 读写分离：写走主库，读按权重分发到从库。
+
+- 从库选择复用 weighted.SW（平滑加权轮询，见 weight/weight_test.go），SW 非并发安全，这里用锁保护
+- CheckReplicas 逐个 Ping 从库，连续失败 FailureThreshold 次的从库被摘除，Ping 恢复后重新加入
+- 所有从库都不可用时读请求退回主库
+- 事务内（Statement.ConnPool 是 *sql.Tx）的读一律走主库，保证看到事务内的写
+- ctx 经过 WithPrimary 标记后读也走主库，用于“写后立即读”（read-your-writes）
+- 读把 Statement.ConnPool 换成从库后不会自动换回，同一条链（q := db.Model(...); q.Find(...); q.Create(...)）
+  接着写时会写到从库上，所以 Create/Update/Delete 和非 SELECT 的 Raw/Exec 之前把连接切回主库
+
+用法：
+	db.Use(NewResolver(Replica{Name: "r1", DB: r1, Weight: 5}, Replica{Name: "r2", DB: r2, Weight: 2}))
+*/
+
+const defaultFailureThreshold = 3
+
+// Replica 从库配置
+type Replica struct {
+	Name   string
+	DB     *sql.DB
+	Weight int
+}
+
+type replicaState struct {
+	Replica
+	failures int
+	healthy  bool
+}
+
+// Resolver 读写分离插件
+type Resolver struct {
+	// FailureThreshold 连续 Ping 失败多少次后摘除从库，为 0 时使用 3
+	FailureThreshold int
+
+	mu       sync.Mutex
+	replicas []*replicaState
+	sw       weighted.SW
+}
+
+// NewResolver 创建读写分离插件
+func NewResolver(replicas ...Replica) *Resolver {
+	r := &Resolver{}
+	for _, rep := range replicas {
+		if rep.Weight <= 0 {
+			rep.Weight = 1
+		}
+		r.replicas = append(r.replicas, &replicaState{Replica: rep, healthy: true})
+	}
+	r.rebuild()
+	return r
+}
+
+type primaryKey struct{}
+
+// WithPrimary 标记 ctx，之后的读请求强制走主库
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+func (r *Resolver) Name() string {
+	return "gormsnippet:resolver"
+}
+
+func (r *Resolver) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("gormsnippet:resolver_query", r.routeRead); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("gormsnippet:resolver_row", r.routeRead); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().Before("gorm:begin_transaction").Register("gormsnippet:resolver_create", r.routeWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:begin_transaction").Register("gormsnippet:resolver_update", r.routeWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:begin_transaction").Register("gormsnippet:resolver_delete", r.routeWrite); err != nil {
+		return err
+	}
+	return db.Callback().Raw().Before("gorm:raw").Register("gormsnippet:resolver_raw", r.routeRaw)
+}
+
+// routeRead 把读请求切换到从库
+func (r *Resolver) routeRead(db *gorm.DB) {
+	if db.Error != nil || usePrimary(db.Statement.Context) {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if pool := r.pick(); pool != nil {
+		db.Statement.ConnPool = pool
+	}
+}
+
+// routeWrite 同一条链上之前的读留下的从库连接换回主库；事务和主库连接保持不动。
+// 要在 gorm:begin_transaction 之前，否则默认事务已经开在从库上了
+func (r *Resolver) routeWrite(db *gorm.DB) {
+	for _, rep := range r.replicas {
+		if db.Statement.ConnPool == gorm.ConnPool(rep.DB) {
+			db.Statement.ConnPool = db.Config.ConnPool
+			return
+		}
+	}
+}
+
+// routeRaw 只有 SELECT 才当作读，SELECT ... FOR UPDATE 需要锁主库上的行
+func (r *Resolver) routeRaw(db *gorm.DB) {
+	sql := strings.ToUpper(strings.TrimSpace(db.Statement.SQL.String()))
+	if strings.HasPrefix(sql, "SELECT") && !strings.Contains(sql, "FOR UPDATE") {
+		r.routeRead(db)
+		return
+	}
+	r.routeWrite(db)
+}
+
+func (r *Resolver) pick() *sql.DB {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if next, ok := r.sw.Next().(*replicaState); ok {
+		return next.DB
+	}
+	return nil
+}
+
+// rebuild 按当前健康的从库重建加权轮询器，调用方需持有锁或处于构造阶段
+func (r *Resolver) rebuild() {
+	r.sw.RemoveAll()
+	for _, rep := range r.replicas {
+		if rep.healthy {
+			r.sw.Add(rep, rep.Weight)
+		}
+	}
+}
+
+// CheckReplicas Ping 所有从库并更新健康状态
+func (r *Resolver) CheckReplicas(ctx context.Context) {
+	threshold := r.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+
+	r.mu.Lock()
+	replicas := append([]*replicaState(nil), r.replicas...)
+	r.mu.Unlock()
+
+	// Ping 可能很慢，不在锁内进行
+	results := make([]error, len(replicas))
+	for i, rep := range replicas {
+		results[i] = rep.DB.PingContext(ctx)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for i, rep := range replicas {
+		if results[i] == nil {
+			rep.failures = 0
+			if !rep.healthy {
+				rep.healthy, changed = true, true
+			}
+			continue
+		}
+		rep.failures++
+		if rep.healthy && rep.failures >= threshold {
+			rep.healthy, changed = false, true
+		}
+	}
+	if changed {
+		r.rebuild()
+	}
+}
+
+// StartHealthCheck 每隔 interval 检查一次从库，ctx 取消后退出
+func (r *Resolver) StartHealthCheck(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.CheckReplicas(ctx)
+			}
+		}
+	}()
+}
+
+// Healthy 返回当前参与读流量的从库名
+func (r *Resolver) Healthy() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for _, rep := range r.replicas {
+		if rep.healthy {
+			names = append(names, rep.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,135 @@
+package gormsnippet
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openSQLiteFile 打开一个文件 SQLite，写入一条 Name=name 的标记记录，用来判断读请求落在哪个库
+func openSQLiteFile(t *testing.T, name string) (*gorm.DB, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name+".db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&User{Name: name}).Error; err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db, sqlDB
+}
+
+func readMarker(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var u User
+	if err := db.Order("id").First(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u.Name
+}
+
+func TestResolver(t *testing.T) {
+	primary, _ := openSQLiteFile(t, "primary")
+	_, r1 := openSQLiteFile(t, "r1")
+	_, r2 := openSQLiteFile(t, "r2")
+
+	resolver := NewResolver(
+		Replica{Name: "r1", DB: r1, Weight: 2},
+		Replica{Name: "r2", DB: r2, Weight: 1},
+	)
+	if err := primary.Use(resolver); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("读按权重分发到从库", func(t *testing.T) {
+		counts := map[string]int{}
+		for i := 0; i < 30; i++ {
+			counts[readMarker(t, primary)]++
+		}
+		assert.Equal(t, map[string]int{"r1": 20, "r2": 10}, counts)
+
+		var name string
+		primary.Raw("SELECT name FROM t_user ORDER BY id LIMIT 1").Scan(&name)
+		assert.Contains(t, []string{"r1", "r2"}, name)
+	})
+
+	t.Run("写走主库", func(t *testing.T) {
+		assert.NoError(t, primary.Create(&User{Name: "written"}).Error)
+		assert.NoError(t, primary.Exec("UPDATE t_user SET email = ? WHERE name = ?", "x@example.com", "written").Error)
+
+		var count int64
+		primary.WithContext(WithPrimary(context.Background())).Model(&User{}).Where("name = ?", "written").Count(&count)
+		assert.Equal(t, int64(1), count)
+		// 从库上没有这条记录
+		primary.Model(&User{}).Where("name = ?", "written").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("同一条链上先读后写，写仍走主库", func(t *testing.T) {
+		onPrimary := func(name string) bool {
+			var count int64
+			primary.WithContext(WithPrimary(context.Background())).Model(&User{}).Where("name = ?", name).Count(&count)
+			return count == 1
+		}
+
+		// 读把 Statement.ConnPool 换成了从库，同一条链上的写要切回主库。
+		// gorm 的 Find 会在 Statement 里留下 FROM 子句，链上再 Update/Delete 生成的 SQL 本身就不合法，这里只能用 Create 和 Exec 验证
+		var users []User
+		q := primary.Model(&User{})
+		q.Find(&users)
+		assert.NoError(t, q.Create(&User{Name: "chained-create"}).Error)
+		assert.True(t, onPrimary("chained-create"))
+
+		q.Find(&users)
+		assert.NoError(t, q.Exec("INSERT INTO t_user (name) VALUES (?)", "chained-exec").Error)
+		assert.True(t, onPrimary("chained-exec"))
+	})
+
+	t.Run("事务内读走主库", func(t *testing.T) {
+		err := primary.Transaction(func(tx *gorm.DB) error {
+			assert.Equal(t, "primary", readMarker(t, tx))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("WithPrimary 固定读主库", func(t *testing.T) {
+		ctx := WithPrimary(context.Background())
+		assert.Equal(t, "primary", readMarker(t, primary.WithContext(ctx)))
+	})
+
+	t.Run("Ping 失败的从库被摘除", func(t *testing.T) {
+		_ = r1.Close()
+		resolver.CheckReplicas(context.Background())
+		resolver.CheckReplicas(context.Background())
+		assert.Equal(t, []string{"r1", "r2"}, resolver.Healthy(), "未达到阈值前不摘除")
+
+		resolver.CheckReplicas(context.Background())
+		assert.Equal(t, []string{"r2"}, resolver.Healthy())
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, "r2", readMarker(t, primary))
+		}
+
+		_ = r2.Close()
+		for i := 0; i < defaultFailureThreshold; i++ {
+			resolver.CheckReplicas(context.Background())
+		}
+		assert.Empty(t, resolver.Healthy())
+		assert.Equal(t, "primary", readMarker(t, primary), "从库全部不可用时退回主库")
+	})
+}
@@ -0,0 +1,131 @@
+package idgensnippet
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	numGoroutines = 64
+	idsPerWorker  = 2000
+)
+
+// generateConcurrently numGoroutines 个 goroutine 各生成 idsPerWorker 个 ID，
+// 按 random_seed_test.go 的做法统计重复：ID -> 生成它的 goroutine 列表
+func generateConcurrently(t *testing.T, next func(goroutineID int) (string, error)) int {
+	results := make([][]string, numGoroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func(goroutineID int) {
+			defer wg.Done()
+			ids := make([]string, idsPerWorker)
+			for j := range ids {
+				id, err := next(goroutineID)
+				if err != nil {
+					t.Errorf("goroutine %d: %v", goroutineID, err)
+					return
+				}
+				ids[j] = id
+			}
+			results[goroutineID] = ids
+		}(i)
+	}
+	wg.Wait()
+
+	idMap := make(map[string][]int)
+	for i, ids := range results {
+		for _, id := range ids {
+			idMap[id] = append(idMap[id], i)
+		}
+	}
+	duplicates := 0
+	for id, goroutineIDs := range idMap {
+		if len(goroutineIDs) > 1 {
+			duplicates++
+			if duplicates <= 3 {
+				t.Logf("重复 ID %s 出现在 Goroutines: %v", id, goroutineIDs)
+			}
+		}
+	}
+	return duplicates
+}
+
+func TestIDCollision(t *testing.T) {
+	t.Run("问题示例：时间戳 + 秒级种子的随机数", func(t *testing.T) {
+		duplicates := generateConcurrently(t, func(int) (string, error) {
+			// 与 TestSeedConcurrencyProblem 相同的错误：同一秒内种子相同，随机部分也相同
+			r := rand.New(rand.NewSource(time.Now().Unix()))
+			return fmt.Sprintf("%d-%d", time.Now().Unix(), r.Intn(1000000)), nil
+		})
+		t.Logf("重复 ID 数: %d", duplicates)
+		assert.Positive(t, duplicates)
+	})
+
+	t.Run("Snowflake 共享生成器", func(t *testing.T) {
+		s, _ := NewSnowflake(SnowflakeConfig{WorkerID: 1})
+		assert.Zero(t, generateConcurrently(t, func(int) (string, error) {
+			id, err := s.Next()
+			return strconv.FormatInt(id, 10), err
+		}))
+	})
+
+	t.Run("Snowflake 每个 goroutine 一个 worker", func(t *testing.T) {
+		workers := make([]*Snowflake, numGoroutines)
+		for i := range workers {
+			workers[i], _ = NewSnowflake(SnowflakeConfig{WorkerID: int64(i)})
+		}
+		assert.Zero(t, generateConcurrently(t, func(g int) (string, error) {
+			id, err := workers[g].Next()
+			return strconv.FormatInt(id, 10), err
+		}))
+	})
+
+	t.Run("Snowflake 误用相同 worker ID", func(t *testing.T) {
+		// 两个实例配置成同一个 worker ID，同一毫秒内的序列号都从 0 开始
+		workers := make([]*Snowflake, numGoroutines)
+		for i := range workers {
+			workers[i], _ = NewSnowflake(SnowflakeConfig{WorkerID: 7})
+		}
+		duplicates := generateConcurrently(t, func(g int) (string, error) {
+			id, err := workers[g].Next()
+			return strconv.FormatInt(id, 10), err
+		})
+		t.Logf("相同 worker ID 的重复 ID 数: %d", duplicates)
+		assert.Positive(t, duplicates)
+	})
+
+	t.Run("ULID 共享生成器", func(t *testing.T) {
+		g := NewULIDGenerator(ULIDConfig{})
+		assert.Zero(t, generateConcurrently(t, func(int) (string, error) {
+			u, err := g.Next()
+			return u.String(), err
+		}))
+	})
+
+	t.Run("ULID 每个 goroutine 一个生成器", func(t *testing.T) {
+		// 不共享状态时靠 80 位随机数避免碰撞
+		gens := make([]*ULIDGenerator, numGoroutines)
+		for i := range gens {
+			gens[i] = NewULIDGenerator(ULIDConfig{})
+		}
+		assert.Zero(t, generateConcurrently(t, func(g int) (string, error) {
+			u, err := gens[g].Next()
+			return u.String(), err
+		}))
+	})
+
+	t.Run("UUIDv7 共享生成器", func(t *testing.T) {
+		g := NewUUIDv7Generator(UUIDv7Config{})
+		assert.Zero(t, generateConcurrently(t, func(int) (string, error) {
+			u, err := g.Next()
+			return u.String(), err
+		}))
+	})
+}
@@ -0,0 +1,128 @@
+package idgensnippet
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+分布式 ID 生成。random 包里演示过：用时间做种子，多个 goroutine 同一时刻得到相同序列；
+ID 生成是同一个问题——只靠“时间 + 随机”在高并发下会撞，必须有单调的计数器或足够多的随机位。
+
+- Snowflake：int64，41 位毫秒时间戳 + 10 位 worker ID + 12 位序列号，每个 worker 每毫秒 4096 个，
+  趋势递增，适合做数据库主键；需要给每个实例分配不同的 worker ID
+- ULID：128 位，48 位毫秒时间戳 + 80 位随机，Crockford base32 编码为 26 个字符，字典序即时间序；
+  同一毫秒内随机部分 +1，保证单调
+- UUIDv7（RFC 9562）：与 ULID 类似，但是标准 UUID 格式，可以直接存进 UUID 类型的列
+
+时钟回拨：三者都维护一个“逻辑时间” = max(当前时间, 上次用的时间)，时钟回拨期间继续用上次的逻辑毫秒，ID 仍然单调不重复。
+- Snowflake 和 UUIDv7 在同一毫秒内序列用完时借用下一毫秒，逻辑时间领先墙上时间超过 MaxDrift 时报错，
+  而不是无限期地透支未来的时间戳
+- ULID 没有 MaxDrift：从不借用未来的毫秒，只在回拨期间沿用上次的时间戳、随机部分 +1 保持单调，
+  回拨多久都不报错，80 位随机部分用完才返回 ErrULIDOverflow
+*/
+
+const (
+	workerBits   = 10
+	sequenceBits = 12
+	// MaxWorkerID worker ID 的上限
+	MaxWorkerID = 1<<workerBits - 1
+	maxSequence = 1<<sequenceBits - 1
+	timeShift   = workerBits + sequenceBits
+	workerShift = sequenceBits
+)
+
+// DefaultEpoch Snowflake 时间戳的起点
+var DefaultEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultMaxDrift 逻辑时间默认最多领先墙上时间多久
+const DefaultMaxDrift = time.Second
+
+var (
+	// ErrInvalidWorkerID worker ID 超出 [0, MaxWorkerID]
+	ErrInvalidWorkerID = errors.New("idgensnippet: invalid worker id")
+	// ErrClockRollback 墙上时间落后逻辑时间超过 MaxDrift
+	ErrClockRollback = errors.New("idgensnippet: clock moved backwards")
+)
+
+// SnowflakeConfig 零值字段使用默认值
+type SnowflakeConfig struct {
+	WorkerID int64
+	Epoch    time.Time         // 默认 DefaultEpoch
+	MaxDrift time.Duration     // 逻辑时间最多领先墙上时间多久，默认 DefaultMaxDrift
+	Clock    timesnippet.Clock // 为空时使用 timesnippet.RealClock
+}
+
+// Snowflake 并发安全的 Snowflake ID 生成器
+type Snowflake struct {
+	cfg   SnowflakeConfig
+	epoch int64 // Epoch 的 Unix 毫秒
+
+	mu       sync.Mutex
+	last     int64 // 上次使用的逻辑毫秒（相对 Epoch）
+	sequence int64
+}
+
+// NewSnowflake 创建生成器
+func NewSnowflake(cfg SnowflakeConfig) (*Snowflake, error) {
+	if cfg.WorkerID < 0 || cfg.WorkerID > MaxWorkerID {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWorkerID, cfg.WorkerID)
+	}
+	if cfg.Epoch.IsZero() {
+		cfg.Epoch = DefaultEpoch
+	}
+	if cfg.MaxDrift <= 0 {
+		cfg.MaxDrift = DefaultMaxDrift
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	return &Snowflake{cfg: cfg, epoch: cfg.Epoch.UnixMilli(), last: -1}, nil
+}
+
+// Next 生成一个 ID
+func (s *Snowflake) Next() (int64, error) {
+	now := s.cfg.Clock.Now().UnixMilli() - s.epoch
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts := max(now, s.last)
+	if ts == s.last {
+		s.sequence++
+		if s.sequence > maxSequence {
+			// 这一毫秒用完了，借用下一毫秒
+			ts++
+			s.sequence = 0
+		}
+	} else {
+		s.sequence = 0
+	}
+	if drift := time.Duration(ts-now) * time.Millisecond; drift > s.cfg.MaxDrift {
+		return 0, fmt.Errorf("%w: logical clock is %v ahead", ErrClockRollback, drift)
+	}
+	s.last = ts
+	return ts<<timeShift | s.cfg.WorkerID<<workerShift | s.sequence, nil
+}
+
+// SnowflakeID 解析后的 Snowflake ID
+type SnowflakeID struct {
+	Time     time.Time
+	WorkerID int64
+	Sequence int64
+}
+
+// ParseSnowflake 按 epoch 解析 ID，epoch 为零值时使用 DefaultEpoch
+func ParseSnowflake(id int64, epoch time.Time) SnowflakeID {
+	if epoch.IsZero() {
+		epoch = DefaultEpoch
+	}
+	return SnowflakeID{
+		Time:     time.UnixMilli(epoch.UnixMilli() + id>>timeShift).UTC(),
+		WorkerID: id >> workerShift & MaxWorkerID,
+		Sequence: id & maxSequence,
+	}
+}
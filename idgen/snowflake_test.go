@@ -0,0 +1,96 @@
+package idgensnippet
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// clockStart 测试里 FakeClock 的起始时刻
+var clockStart = time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+
+// rollbackClock 可以往回拨的时钟，FakeClock 只能前进；生成器只调用 Now，其余方法留给内嵌的 nil Clock
+type rollbackClock struct {
+	timesnippet.Clock
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *rollbackClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *rollbackClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSnowflake(t *testing.T) {
+	t.Run("布局与解析", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		s, err := NewSnowflake(SnowflakeConfig{WorkerID: 5, Clock: clock})
+		assert.NoError(t, err)
+		id1, _ := s.Next()
+		id2, _ := s.Next()
+		assert.Less(t, id1, id2)
+
+		p := ParseSnowflake(id2, time.Time{})
+		assert.Equal(t, clock.Now(), p.Time)
+		assert.Equal(t, int64(5), p.WorkerID)
+		assert.Equal(t, int64(1), p.Sequence)
+
+		clock.Advance(time.Millisecond)
+		id3, _ := s.Next()
+		assert.Equal(t, int64(0), ParseSnowflake(id3, time.Time{}).Sequence, "新的一毫秒序列号归零")
+	})
+
+	t.Run("非法 worker ID", func(t *testing.T) {
+		for _, id := range []int64{-1, MaxWorkerID + 1} {
+			_, err := NewSnowflake(SnowflakeConfig{WorkerID: id})
+			assert.ErrorIs(t, err, ErrInvalidWorkerID)
+		}
+	})
+
+	t.Run("序列号用完借用下一毫秒", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		s, _ := NewSnowflake(SnowflakeConfig{Clock: clock})
+		var last int64
+		for i := 0; i <= maxSequence+1; i++ {
+			id, err := s.Next()
+			assert.NoError(t, err)
+			assert.Greater(t, id, last)
+			last = id
+		}
+		p := ParseSnowflake(last, time.Time{})
+		assert.Equal(t, clock.Now().Add(time.Millisecond), p.Time)
+		assert.Equal(t, int64(0), p.Sequence)
+	})
+
+	t.Run("小幅回拨保持单调，超过 MaxDrift 报错", func(t *testing.T) {
+		clock := &rollbackClock{now: clockStart}
+		s, _ := NewSnowflake(SnowflakeConfig{Clock: clock, MaxDrift: 100 * time.Millisecond})
+		before, _ := s.Next()
+
+		clock.Add(-50 * time.Millisecond)
+		during, err := s.Next()
+		assert.NoError(t, err)
+		assert.Greater(t, during, before)
+		assert.Equal(t, ParseSnowflake(before, time.Time{}).Time, ParseSnowflake(during, time.Time{}).Time, "沿用上次的逻辑时间")
+
+		clock.Add(-time.Second)
+		_, err = s.Next()
+		assert.ErrorIs(t, err, ErrClockRollback)
+
+		clock.Add(2 * time.Second)
+		after, err := s.Next()
+		assert.NoError(t, err)
+		assert.Greater(t, after, during)
+	})
+}
@@ -0,0 +1,144 @@
+package idgensnippet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	randomsnippet "github.com/A0dongq1N/golang_snippet/random"
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+ULID 布局（大端）：
+
+	 0                   6                                      16
+	| 48 位 Unix 毫秒时间戳 | 80 位随机数                           |
+
+编码为 26 个 Crockford base32 字符（0-9A-Z 去掉 I L O U），前 10 个字符是时间，字典序即时间序。
+同一毫秒内的后续 ID 把上一个的随机部分当作 80 位整数 +1；溢出（同一毫秒内生成了约 2^80 个）时报错。
+*/
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	// ErrULIDOverflow 同一毫秒内随机部分溢出
+	ErrULIDOverflow = errors.New("idgensnippet: ulid entropy overflow within the same millisecond")
+	// ErrInvalidULID 无法解析的 ULID 字符串
+	ErrInvalidULID = errors.New("idgensnippet: invalid ulid")
+)
+
+// ULID 128 位 ID
+type ULID [16]byte
+
+// Time ID 中的时间戳
+func (u ULID) Time() time.Time {
+	var b [8]byte
+	copy(b[2:], u[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(b[:]))).UTC()
+}
+
+// String 26 个字符的 Crockford base32
+func (u ULID) String() string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	var out [26]byte
+	// 128 位按 5 位一组从低位往高位取，最高一组只有 3 位
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// ParseULID 解析 26 个字符的 ULID，大小写不敏感
+func ParseULID(s string) (ULID, error) {
+	if len(s) != 26 {
+		return ULID{}, fmt.Errorf("%w: length %d", ErrInvalidULID, len(s))
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := decodeCrockford(s[i])
+		if v < 0 || (i == 0 && v > 7) {
+			return ULID{}, fmt.Errorf("%w: %q", ErrInvalidULID, s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	var u ULID
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+func decodeCrockford(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(crockford); i++ {
+		if crockford[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// ULIDConfig 零值字段使用默认值；ULID 不借用未来的毫秒，没有 MaxDrift
+type ULIDConfig struct {
+	Clock   timesnippet.Clock // 为空时使用 timesnippet.RealClock
+	Entropy io.Reader         // 为空时使用带缓冲的 crypto/rand
+}
+
+// ULIDGenerator 并发安全的单调 ULID 生成器
+type ULIDGenerator struct {
+	cfg ULIDConfig
+
+	mu   sync.Mutex
+	last ULID
+	ms   int64 // last 的时间戳，-1 表示还没生成过
+}
+
+// NewULIDGenerator 创建 ULID 生成器
+func NewULIDGenerator(cfg ULIDConfig) *ULIDGenerator {
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	if cfg.Entropy == nil {
+		cfg.Entropy = randomsnippet.NewSecureReader(nil)
+	}
+	return &ULIDGenerator{cfg: cfg, ms: -1}
+}
+
+// Next 生成一个 ULID，同一毫秒内（包括时钟回拨期间）严格递增
+func (g *ULIDGenerator) Next() (ULID, error) {
+	now := g.cfg.Clock.Now().UnixMilli()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now <= g.ms {
+		u := g.last
+		// 随机部分 u[6:] 作为 80 位大端整数 +1
+		for i := 15; i >= 6; i-- {
+			u[i]++
+			if u[i] != 0 {
+				g.last = u
+				return u, nil
+			}
+		}
+		return ULID{}, ErrULIDOverflow
+	}
+
+	var u ULID
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(now))
+	copy(u[:6], ts[2:])
+	if _, err := io.ReadFull(g.cfg.Entropy, u[6:]); err != nil {
+		return ULID{}, err
+	}
+	g.last, g.ms = u, now
+	return u, nil
+}
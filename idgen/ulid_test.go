@@ -0,0 +1,70 @@
+package idgensnippet
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+func TestULID(t *testing.T) {
+	t.Run("编码与解析", func(t *testing.T) {
+		// ulid 规范里的例子
+		u, err := ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1469922850259), u.Time().UnixMilli())
+		assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", u.String())
+
+		lower, err := ParseULID(strings.ToLower(u.String()))
+		assert.NoError(t, err)
+		assert.Equal(t, u, lower)
+
+		max := ULID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", max.String())
+
+		for _, s := range []string{"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+			_, err := ParseULID(s)
+			assert.ErrorIs(t, err, ErrInvalidULID, s)
+		}
+	})
+
+	t.Run("同一毫秒内单调递增", func(t *testing.T) {
+		clock := &rollbackClock{now: clockStart}
+		g := NewULIDGenerator(ULIDConfig{Clock: clock})
+		var ids []string
+		for i := 0; i < 1000; i++ {
+			if i%100 == 0 {
+				clock.Add(time.Millisecond)
+			}
+			u, err := g.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, clock.Now(), u.Time())
+			ids = append(ids, u.String())
+		}
+		assert.True(t, slices.IsSorted(ids), "字典序即生成顺序")
+
+		// 回拨期间沿用上次的时间戳继续递增
+		last, _ := ParseULID(ids[len(ids)-1])
+		clock.Add(-time.Second)
+		u, _ := g.Next()
+		assert.Equal(t, last.Time(), u.Time())
+		assert.Greater(t, u.String(), last.String())
+	})
+
+	t.Run("随机部分溢出报错", func(t *testing.T) {
+		// 熵全是 0xff，同一毫秒内第二个就会溢出
+		g := NewULIDGenerator(ULIDConfig{
+			Clock:   timesnippet.NewFakeClock(clockStart),
+			Entropy: bytes.NewReader(bytes.Repeat([]byte{0xff}, 10)),
+		})
+		_, err := g.Next()
+		assert.NoError(t, err)
+		_, err = g.Next()
+		assert.ErrorIs(t, err, ErrULIDOverflow)
+	})
+}
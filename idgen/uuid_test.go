@@ -0,0 +1,82 @@
+package idgensnippet
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+func TestUUIDv7(t *testing.T) {
+	t.Run("版本、变体与时间戳", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		u, err := NewUUIDv7Generator(UUIDv7Config{Clock: clock}).Next()
+		assert.NoError(t, err)
+		assert.Equal(t, 7, u.Version())
+		assert.Equal(t, byte(0x80), u[8]&0xc0, "variant 10")
+		assert.Equal(t, clock.Now(), u.Time())
+
+		s := u.String()
+		assert.Len(t, s, 36)
+		assert.Equal(t, byte('7'), s[14])
+		parsed, err := ParseUUID(s)
+		assert.NoError(t, err)
+		assert.Equal(t, u, parsed)
+
+		_, err = ParseUUID("not-a-uuid")
+		assert.Error(t, err)
+	})
+
+	t.Run("同一毫秒内单调，计数器用完借用下一毫秒", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		g := NewUUIDv7Generator(UUIDv7Config{Clock: clock})
+		var ids []string
+		var last UUID
+		for i := 0; i < 5000; i++ {
+			u, err := g.Next()
+			assert.NoError(t, err)
+			ids = append(ids, u.String())
+			last = u
+		}
+		assert.True(t, slices.IsSorted(ids))
+		// 计数器从 < 2048 开始，5000 个至少借用一毫秒
+		assert.True(t, last.Time().After(clock.Now()))
+
+		// 时钟追上后恢复使用真实时间
+		clock.Advance(10 * time.Millisecond)
+		u, _ := g.Next()
+		assert.Equal(t, clock.Now(), u.Time())
+	})
+	t.Run("借用未来时间或回拨超过 MaxDrift 报错", func(t *testing.T) {
+		clock := &rollbackClock{now: clockStart}
+		g := NewUUIDv7Generator(UUIDv7Config{Clock: clock, MaxDrift: 2 * time.Millisecond})
+		var last UUID
+		var err error
+		for i := 0; i < 4*(maxCounter+1); i++ {
+			var u UUID
+			if u, err = g.Next(); err != nil {
+				break
+			}
+			last = u
+		}
+		assert.ErrorIs(t, err, ErrClockRollback, "同一毫秒内最多借用到 now+MaxDrift")
+		assert.Equal(t, clockStart.Add(2*time.Millisecond), last.Time())
+
+		clock.Add(10 * time.Millisecond)
+		u, err := g.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, clock.Now(), u.Time())
+
+		clock.Add(-time.Second)
+		_, err = g.Next()
+		assert.ErrorIs(t, err, ErrClockRollback)
+
+		clock.Add(time.Second)
+		next, err := g.Next()
+		assert.NoError(t, err)
+		assert.Greater(t, next.String(), u.String())
+	})
+}
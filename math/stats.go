@@ -0,0 +1,63 @@
+package mathsnippet
+
+import "math"
+
+/*
+统计检验用到的分布函数，weight 的分布验证和 random 的随机源质量检验共用。
+
+- ChiSquareSurvival：卡方分布的右尾概率，即拟合优度检验的 p 值
+- gammaQ：正则化上不完全伽马函数，卡方分布 P(X >= x) = Q(df/2, x/2)
+*/
+
+// ChiSquareSurvival 自由度为 df 的卡方分布 P(X >= x)
+func ChiSquareSurvival(x float64, df int) float64 {
+	if df <= 0 {
+		return 1
+	}
+	if x <= 0 {
+		return 1
+	}
+	return gammaQ(float64(df)/2, x/2)
+}
+
+// gammaQ 正则化上不完全伽马函数 Q(a, x)，x < a+1 时用级数，否则用连分式（Numerical Recipes 6.2）
+func gammaQ(a, x float64) float64 {
+	lg, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < 1000; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return 1 - sum*prefix
+	}
+
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return prefix * h
+}
@@ -0,0 +1,23 @@
+package mathsnippet
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChiSquareSurvival(t *testing.T) {
+	// 常用临界值：P(X >= x) = 0.05
+	for _, c := range []struct {
+		x  float64
+		df int
+	}{{3.841, 1}, {5.991, 2}, {7.815, 3}, {11.070, 5}, {18.307, 10}, {124.342, 100}} {
+		assert.InDelta(t, 0.05, ChiSquareSurvival(c.x, c.df), 1e-3, "df=%d", c.df)
+	}
+	// df=2 时有闭式解 e^(-x/2)
+	for _, x := range []float64{0.5, 1, 4, 20} {
+		assert.InDelta(t, math.Exp(-x/2), ChiSquareSurvival(x, 2), 1e-12)
+	}
+	assert.Equal(t, 1.0, ChiSquareSurvival(0, 3))
+}
@@ -0,0 +1,128 @@
+package metersnippet
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+/*
+HDR 风格的延迟直方图：对数-线性分桶，固定相对误差，内存与样本数无关。
+
+- 小于 2^(p+1) 的值每个值一个桶（精确）
+- 之后每个 [2^k, 2^(k+1)) 区间平均分成 2^p 个桶，桶宽 2^(k-p)
+- p=7 时相对误差不超过 1/128（< 1%），覆盖 0 到 int64 最大值共约 7300 个桶
+- Quantile 返回所在桶的上界（与 HdrHistogram 的 highestEquivalentValue 一致），偏保守
+*/
+
+const (
+	precisionBits = 7
+	subBuckets    = 1 << precisionBits
+	linearLimit   = subBuckets << 1 // 小于它的值精确记录
+	bucketCount   = (63-precisionBits)*subBuckets + subBuckets
+)
+
+func bucketIndex(v int64) int {
+	if v < linearLimit {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - 1 - precisionBits
+	return shift*subBuckets + int(v>>shift)
+}
+
+// bucketUpper 桶内的最大值
+func bucketUpper(idx int) int64 {
+	if idx < linearLimit {
+		return int64(idx)
+	}
+	shift := idx>>precisionBits - 1
+	lower := int64(idx-shift*subBuckets) << shift
+	return lower + (int64(1) << shift) - 1
+}
+
+// Histogram 延迟直方图，并发安全
+type Histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	total  int64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+// NewHistogram 创建直方图
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, bucketCount), min: math.MaxInt64}
+}
+
+// Record 记录一次延迟，负数按 0 记录
+func (h *Histogram) Record(d time.Duration) {
+	v := max(int64(d), 0)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[bucketIndex(v)]++
+	h.total++
+	h.sum += float64(v)
+	h.min = min(h.min, v)
+	h.max = max(h.max, v)
+}
+
+// Count 样本数
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// Quantile 分位数，q 取 [0, 1]
+func (h *Histogram) Quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quantileLocked(q)
+}
+
+func (h *Histogram) quantileLocked(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return time.Duration(h.min)
+	}
+	rank := uint64(math.Ceil(min(q, 1) * float64(h.total)))
+	var seen uint64
+	for idx, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return time.Duration(min(bucketUpper(idx), h.max))
+		}
+	}
+	return time.Duration(h.max)
+}
+
+// Reset 清空
+func (h *Histogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.counts)
+	h.total, h.sum, h.min, h.max = 0, 0, math.MaxInt64, 0
+}
+
+// Snapshot 当前的延迟分布
+func (h *Histogram) Snapshot() LatencySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return LatencySnapshot{}
+	}
+	return LatencySnapshot{
+		Count: h.total,
+		Min:   time.Duration(h.min),
+		Mean:  time.Duration(h.sum / float64(h.total)),
+		Max:   time.Duration(h.max),
+		P50:   h.quantileLocked(0.5),
+		P90:   h.quantileLocked(0.9),
+		P99:   h.quantileLocked(0.99),
+		P999:  h.quantileLocked(0.999),
+	}
+}
@@ -0,0 +1,66 @@
+package metersnippet
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketIndex(t *testing.T) {
+	t.Run("小值精确", func(t *testing.T) {
+		for v := int64(0); v < linearLimit; v++ {
+			assert.Equal(t, v, bucketUpper(bucketIndex(v)))
+		}
+	})
+
+	t.Run("相对误差小于1%", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100000; i++ {
+			v := r.Int63() >> r.Intn(63)
+			upper := bucketUpper(bucketIndex(v))
+			assert.GreaterOrEqual(t, upper, v)
+			assert.LessOrEqual(t, float64(upper-v), float64(v)/subBuckets)
+		}
+		assert.Equal(t, bucketCount-1, bucketIndex(1<<63-1))
+	})
+
+	t.Run("桶连续", func(t *testing.T) {
+		for idx := 1; idx < bucketCount; idx++ {
+			assert.Equal(t, idx, bucketIndex(bucketUpper(idx-1)+1))
+		}
+	})
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	assert.Equal(t, LatencySnapshot{}, h.Snapshot())
+
+	// 对数正态分布的延迟，和排序后的精确分位数比较
+	r := rand.New(rand.NewSource(1))
+	samples := make([]time.Duration, 100000)
+	for i := range samples {
+		samples[i] = time.Duration(r.ExpFloat64() * float64(time.Millisecond))
+		h.Record(samples[i])
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		exact := samples[int(q*float64(len(samples)))-1]
+		got := h.Quantile(q)
+		assert.InEpsilon(t, float64(exact), float64(got), 0.01, "q=%v", q)
+	}
+	s := h.Snapshot()
+	assert.Equal(t, int64(len(samples)), s.Count)
+	assert.Equal(t, samples[0], s.Min)
+	assert.Equal(t, samples[len(samples)-1], s.Max)
+	assert.Equal(t, samples[len(samples)-1], h.Quantile(1))
+	assert.InEpsilon(t, float64(time.Millisecond), float64(s.Mean), 0.02)
+
+	h.Reset()
+	assert.Equal(t, int64(0), h.Count())
+	h.Record(-time.Second)
+	assert.Equal(t, time.Duration(0), h.Quantile(0.5))
+}
@@ -0,0 +1,208 @@
+package metersnippet
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+吞吐与延迟统计：
+
+	m := NewMeter(MeterConfig{Name: "redis.pipeline"})
+	start := time.Now()
+	_, err := pipe.Exec(ctx)
+	m.Since(start)          // 计数 + 记录延迟
+	fmt.Println(m.Snapshot()) // 或 WriteJSON(os.Stdout, m.Snapshot())
+
+批量操作（一次 Pipeline 执行 n 条命令）用 m.ObserveN(n, time.Since(start))：吞吐按 n 计，延迟按批记一次。
+
+- Rate：滑动窗口速率，只统计已经结束的桶，窗口 [当前桶起点-Window, 当前桶起点)，结果稳定不抖动，代价是最多滞后一个桶
+- M1/M5/M15：与 Unix load average、Dropwizard Metrics 相同的 1/5/15 分钟指数加权移动平均，每 5 秒衰减一次
+- MeanRate：创建以来的平均速率
+- Latency：HDR 风格直方图的分位数
+*/
+
+const tickInterval = 5 * time.Second
+
+// ewma 指数加权移动平均，单位为每秒
+type ewma struct {
+	alpha float64
+	rate  float64
+	init  bool
+}
+
+func newEWMA(minutes float64) ewma {
+	return ewma{alpha: 1 - math.Exp(-tickInterval.Seconds()/60/minutes)}
+}
+
+func (e *ewma) tick(count int64) {
+	instant := float64(count) / tickInterval.Seconds()
+	if !e.init {
+		e.rate, e.init = instant, true
+		return
+	}
+	e.rate += e.alpha * (instant - e.rate)
+}
+
+// decay 连续 n 个没有事件的周期
+func (e *ewma) decay(n int64) {
+	e.rate *= math.Pow(1-e.alpha, float64(n))
+}
+
+// MeterConfig 零值字段使用默认值
+type MeterConfig struct {
+	Name    string
+	Window  time.Duration     // 滑动窗口长度，默认 10s
+	Buckets int               // 窗口分桶数，默认 10
+	Clock   timesnippet.Clock // 为空时使用 timesnippet.RealClock
+}
+
+// Meter 吞吐与延迟统计，并发安全
+type Meter struct {
+	cfg        MeterConfig
+	bucketSize time.Duration
+	hist       *Histogram
+	created    time.Time
+
+	mu        sync.Mutex
+	count     int64
+	buckets   []int64
+	starts    []time.Time
+	rates     [3]ewma
+	lastTick  time.Time
+	uncounted int64
+}
+
+// NewMeter 创建 Meter
+func NewMeter(cfg MeterConfig) *Meter {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 10
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	now := cfg.Clock.Now()
+	return &Meter{
+		cfg:        cfg,
+		bucketSize: cfg.Window / time.Duration(cfg.Buckets),
+		hist:       NewHistogram(),
+		created:    now,
+		buckets:    make([]int64, cfg.Buckets+1), // 多一个给还没结束的当前桶
+		starts:     make([]time.Time, cfg.Buckets+1),
+		rates:      [3]ewma{newEWMA(1), newEWMA(5), newEWMA(15)},
+		lastTick:   now,
+	}
+}
+
+// Mark 记录 n 次事件
+func (m *Meter) Mark(n int64) {
+	now := m.cfg.Clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tickLocked(now)
+	m.count += n
+	m.uncounted += n
+
+	start := now.Truncate(m.bucketSize)
+	idx := int(start.UnixNano()/int64(m.bucketSize)) % len(m.buckets)
+	if !m.starts[idx].Equal(start) {
+		m.starts[idx], m.buckets[idx] = start, 0
+	}
+	m.buckets[idx] += n
+}
+
+// Observe 记录一次耗时为 d 的事件
+func (m *Meter) Observe(d time.Duration) {
+	m.ObserveN(1, d)
+}
+
+// ObserveN 记录一批共 n 次、整批耗时为 d 的事件：计数加 n，延迟直方图只记一次 d
+func (m *Meter) ObserveN(n int64, d time.Duration) {
+	m.Mark(n)
+	m.hist.Record(d)
+}
+
+// Since 记录一次从 start 开始到现在的事件
+func (m *Meter) Since(start time.Time) {
+	m.Observe(m.cfg.Clock.Now().Sub(start))
+}
+
+// Time 执行 fn 并记录耗时
+func (m *Meter) Time(fn func()) {
+	start := m.cfg.Clock.Now()
+	fn()
+	m.Since(start)
+}
+
+// Count 总事件数
+func (m *Meter) Count() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.count
+}
+
+// Rate 滑动窗口内每秒事件数
+func (m *Meter) Rate() float64 {
+	now := m.cfg.Clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rateLocked(now)
+}
+
+func (m *Meter) rateLocked(now time.Time) float64 {
+	end := now.Truncate(m.bucketSize)
+	begin := end.Add(-m.cfg.Window)
+	var sum int64
+	for i, start := range m.starts {
+		if !start.Before(begin) && start.Before(end) {
+			sum += m.buckets[i]
+		}
+	}
+	return float64(sum) / m.cfg.Window.Seconds()
+}
+
+// tickLocked 补上从 lastTick 到 now 之间的 EWMA 周期
+func (m *Meter) tickLocked(now time.Time) {
+	n := int64(now.Sub(m.lastTick) / tickInterval)
+	if n <= 0 {
+		return
+	}
+	for i := range m.rates {
+		m.rates[i].tick(m.uncounted)
+		m.rates[i].decay(n - 1)
+	}
+	m.uncounted = 0
+	m.lastTick = m.lastTick.Add(time.Duration(n) * tickInterval)
+}
+
+// Histogram 延迟直方图
+func (m *Meter) Histogram() *Histogram {
+	return m.hist
+}
+
+// Snapshot 当前统计
+func (m *Meter) Snapshot() Snapshot {
+	now := m.cfg.Clock.Now()
+	m.mu.Lock()
+	m.tickLocked(now)
+	s := Snapshot{
+		Name:  m.cfg.Name,
+		Count: m.count,
+		Rate:  m.rateLocked(now),
+		M1:    m.rates[0].rate,
+		M5:    m.rates[1].rate,
+		M15:   m.rates[2].rate,
+	}
+	if elapsed := now.Sub(m.created); elapsed > 0 {
+		s.MeanRate = float64(m.count) / elapsed.Seconds()
+	}
+	m.mu.Unlock()
+	s.Latency = m.hist.Snapshot()
+	return s
+}
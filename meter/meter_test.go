@@ -0,0 +1,127 @@
+package metersnippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// clockStart 测试里 FakeClock 的起始时刻
+var clockStart = time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+
+func TestMeterRate(t *testing.T) {
+	clock := timesnippet.NewFakeClock(clockStart)
+	m := NewMeter(MeterConfig{Window: time.Second, Clock: clock})
+
+	// 每 100ms 10 个，即 100/s
+	for i := 0; i < 20; i++ {
+		m.Mark(10)
+		clock.Advance(100 * time.Millisecond)
+	}
+	assert.Equal(t, 100.0, m.Rate())
+
+	// 当前桶还没结束，不计入
+	m.Mark(1000)
+	assert.Equal(t, 100.0, m.Rate())
+	clock.Advance(100 * time.Millisecond)
+	assert.Equal(t, 1090.0, m.Rate())
+
+	// 窗口内没有事件
+	clock.Advance(time.Second)
+	assert.Equal(t, 0.0, m.Rate())
+	assert.Equal(t, int64(1200), m.Count())
+}
+
+func TestMeterEWMA(t *testing.T) {
+	clock := timesnippet.NewFakeClock(clockStart)
+	m := NewMeter(MeterConfig{Clock: clock})
+
+	// 5 分钟内稳定 100/s
+	for i := 0; i < 60; i++ {
+		m.Mark(500)
+		clock.Advance(5 * time.Second)
+	}
+	s := m.Snapshot()
+	assert.InDelta(t, 100, s.M1, 1e-9)
+	assert.InDelta(t, 100, s.M5, 1e-9)
+	assert.InDelta(t, 100, s.M15, 1e-9)
+	assert.InDelta(t, 100, s.MeanRate, 1e-9)
+
+	// 停止 1 分钟后，M1 衰减到 1/e，M5、M15 衰减得更慢
+	clock.Advance(time.Minute)
+	s = m.Snapshot()
+	assert.InDelta(t, 100*math.Exp(-1), s.M1, 1e-9)
+	assert.InDelta(t, 100*math.Exp(-1.0/5), s.M5, 1e-9)
+	assert.InDelta(t, 100*math.Exp(-1.0/15), s.M15, 1e-9)
+}
+
+func TestMeterReport(t *testing.T) {
+	clock := timesnippet.NewFakeClock(clockStart)
+	m := NewMeter(MeterConfig{Name: "demo", Window: time.Second, Clock: clock})
+	for i := 1; i <= 100; i++ {
+		m.Time(func() { clock.Advance(time.Duration(i) * time.Millisecond) })
+	}
+
+	s := m.Snapshot()
+	assert.Equal(t, int64(100), s.Latency.Count)
+	assert.Equal(t, time.Millisecond, s.Latency.Min)
+	assert.Equal(t, 100*time.Millisecond, s.Latency.Max)
+	assert.InEpsilon(t, float64(50*time.Millisecond), float64(s.Latency.P50), 0.01)
+	assert.InEpsilon(t, float64(99*time.Millisecond), float64(s.Latency.P99), 0.01)
+
+	var text bytes.Buffer
+	assert.NoError(t, WriteText(&text, s))
+	t.Log(text.String())
+	assert.True(t, strings.HasPrefix(text.String(), "demo count=100 "))
+	assert.Contains(t, text.String(), "p99=")
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJSON(&buf, s))
+	var decoded []map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "demo", decoded[0]["name"])
+	latency := decoded[0]["latency"].(map[string]any)
+	assert.Equal(t, 100.0, latency["max_ms"])
+	assert.Equal(t, 1.0, latency["min_ms"])
+}
+
+func TestMeterObserveN(t *testing.T) {
+	clock := timesnippet.NewFakeClock(clockStart)
+	m := NewMeter(MeterConfig{Window: time.Second, Clock: clock})
+	for i := 0; i < 10; i++ {
+		m.ObserveN(100, 20*time.Millisecond)
+		clock.Advance(100 * time.Millisecond)
+	}
+
+	s := m.Snapshot()
+	assert.Equal(t, int64(1000), s.Count)
+	assert.Equal(t, 1000.0, s.Rate)
+	assert.Equal(t, int64(10), s.Latency.Count, "每批只记一次延迟")
+	assert.Equal(t, 20*time.Millisecond, s.Latency.Max)
+}
+
+func TestMeterConcurrent(t *testing.T) {
+	m := NewMeter(MeterConfig{})
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.Observe(time.Duration(i) * time.Microsecond)
+			}
+			_ = m.Snapshot()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(10000), m.Count())
+	assert.Equal(t, int64(10000), m.Histogram().Count())
+}
@@ -0,0 +1,79 @@
+package metersnippet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// LatencySnapshot 延迟分布，JSON 中以毫秒表示
+type LatencySnapshot struct {
+	Count int64
+	Min   time.Duration
+	Mean  time.Duration
+	Max   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	P999  time.Duration
+}
+
+func (l LatencySnapshot) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(struct {
+		Count int64   `json:"count"`
+		Min   float64 `json:"min_ms"`
+		Mean  float64 `json:"mean_ms"`
+		Max   float64 `json:"max_ms"`
+		P50   float64 `json:"p50_ms"`
+		P90   float64 `json:"p90_ms"`
+		P99   float64 `json:"p99_ms"`
+		P999  float64 `json:"p999_ms"`
+	}{l.Count, ms(l.Min), ms(l.Mean), ms(l.Max), ms(l.P50), ms(l.P90), ms(l.P99), ms(l.P999)})
+}
+
+// Snapshot 某一时刻的统计结果
+type Snapshot struct {
+	Name     string          `json:"name,omitempty"`
+	Count    int64           `json:"count"`
+	Rate     float64         `json:"rate"`
+	MeanRate float64         `json:"mean_rate"`
+	M1       float64         `json:"m1"`
+	M5       float64         `json:"m5"`
+	M15      float64         `json:"m15"`
+	Latency  LatencySnapshot `json:"latency"`
+}
+
+// String 单行文本，没有延迟样本时省略延迟部分
+func (s Snapshot) String() string {
+	var b strings.Builder
+	if s.Name != "" {
+		b.WriteString(s.Name + " ")
+	}
+	fmt.Fprintf(&b, "count=%d rate=%.2f/s mean=%.2f/s m1=%.2f m5=%.2f m15=%.2f",
+		s.Count, s.Rate, s.MeanRate, s.M1, s.M5, s.M15)
+	if l := s.Latency; l.Count > 0 {
+		fmt.Fprintf(&b, " latency(n=%d) min=%v mean=%v p50=%v p90=%v p99=%v p999=%v max=%v",
+			l.Count, l.Min, l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	return b.String()
+}
+
+// WriteText 每个快照一行
+func WriteText(w io.Writer, snaps ...Snapshot) error {
+	for _, s := range snaps {
+		if _, err := fmt.Fprintln(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON 输出 JSON 数组
+func WriteJSON(w io.Writer, snaps ...Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snaps)
+}
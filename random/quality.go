@@ -0,0 +1,234 @@
+package randomsnippet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	mathsnippet "github.com/A0dongq1N/golang_snippet/math"
+)
+
+/*
+随机数质量检验。TestRandomConcurrency 只数“生成了多少个不同的值”，这既发现不了偏差也发现不了相关性。
+这里的每个检验都给出 p 值：假设生成器是理想的，出现当前或更极端统计量的概率。
+p 很小（如 < 0.001）说明生成器有问题；理想生成器也会以 alpha 的概率“失败”，所以阈值不要取太大。
+
+- ChiSquareUniform：k 个桶的卡方拟合优度检验，检查取值是否均匀，能发现取模偏差
+- Runs：把输出看成比特流，统计连续相同比特的段数（Wald-Wolfowitz），太多或太少都说明比特之间不独立
+- SerialCorrelation：相邻两个输出（映射到 [0, 1)）的相关系数，理想情况下约为 N(0, 1/n)
+- BirthdaySpacings（Marsaglia）：m 个生日落在 d 天里，排序后相邻间距中重复值的个数近似 Poisson(m³/4d)，
+  对线性同余等格点结构的生成器很敏感
+
+rand.Source 直接检验；io.Reader（比如 crypto/rand.Reader）用 NewReaderSource 包装成 rand.Source，读取错误通过 Err 取出。
+
+关于 random_seed_test.go 里 crypto_rand 子测试的 uint32 % 1000：2^32 % 1000 = 296，
+0..295 比其它值多出现 1/4294967，靠采样要上万亿次才能看出来，用 ModuloBias 直接算；
+同样的写法换成 uint16 % 1000 偏差放大到约 1.5%，一千万次采样的卡方检验就能稳定发现。
+*/
+
+// TestResult 一项检验的结果
+type TestResult struct {
+	Name      string
+	N         int     // 样本数
+	Statistic float64 // 检验统计量
+	PValue    float64
+}
+
+// Failed p 值小于 alpha 时认为检验不通过
+func (r TestResult) Failed(alpha float64) bool {
+	return r.PValue < alpha
+}
+
+func (r TestResult) String() string {
+	return fmt.Sprintf("%-18s n=%-9d stat=%-12.4f p=%.6f", r.Name, r.N, r.Statistic, r.PValue)
+}
+
+// ReaderSource 把 io.Reader 当作 rand.Source，每次读 8 字节作为一个 Uint64。
+// rand.Source 没法返回错误：读取失败后 Uint64 一直返回 0，第一个错误由 Err 取出，
+// 检验结果只有在 Err 为 nil 时才有意义
+type ReaderSource struct {
+	r   io.Reader
+	buf [8]byte
+	err error
+}
+
+// NewReaderSource 包装 r
+func NewReaderSource(r io.Reader) *ReaderSource {
+	return &ReaderSource{r: r}
+}
+
+func (s *ReaderSource) Uint64() uint64 {
+	if s.err != nil {
+		return 0
+	}
+	if _, err := io.ReadFull(s.r, s.buf[:]); err != nil {
+		s.err = err
+		return 0
+	}
+	return binary.LittleEndian.Uint64(s.buf[:])
+}
+
+// Err 第一次读取失败的错误
+func (s *ReaderSource) Err() error {
+	return s.err
+}
+
+// normalTwoSided 标准正态分布的双侧 p 值
+func normalTwoSided(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// ChiSquareUniform 从 sample 取 n 个 [0, k) 上的值，检验是否均匀
+func ChiSquareUniform(sample func() int, k, n int) TestResult {
+	counts := make([]int, k)
+	for i := 0; i < n; i++ {
+		counts[sample()]++
+	}
+	expected := float64(n) / float64(k)
+	chi2 := 0.0
+	for _, c := range counts {
+		d := float64(c) - expected
+		chi2 += d * d / expected
+	}
+	return TestResult{Name: "chi-square", N: n, Statistic: chi2, PValue: mathsnippet.ChiSquareSurvival(chi2, k-1)}
+}
+
+// Uniform 用 Uint64N 从 src 得到 [0, k) 上的无偏整数，作为 ChiSquareUniform 的 sample
+func Uniform(src rand.Source, k int) func() int {
+	return func() int { return IntN(src.Uint64, k) }
+}
+
+// Runs 对 n 个 64 位输出组成的比特流做游程检验
+func Runs(src rand.Source, n int) TestResult {
+	var ones, runs float64
+	last := uint64(2) // 第一个比特一定开始新的一段
+	for i := 0; i < n; i++ {
+		x := src.Uint64()
+		ones += float64(bits.OnesCount64(x))
+		// 字内相邻比特不同的次数 + 与上一个字的衔接
+		runs += float64(bits.OnesCount64((x ^ x>>1) & (1<<63 - 1)))
+		if x>>63 != last {
+			runs++
+		}
+		last = x & 1
+	}
+	total := float64(n) * 64
+	zeros := total - ones
+	mean := 2*ones*zeros/total + 1
+	variance := (mean - 1) * (mean - 2) / (total - 1)
+	z := (runs - mean) / math.Sqrt(variance)
+	return TestResult{Name: "runs", N: n, Statistic: z, PValue: normalTwoSided(z)}
+}
+
+// toUnit 取高 53 位映射到 [0, 1)
+func toUnit(x uint64) float64 {
+	return float64(x>>11) / (1 << 53)
+}
+
+// SerialCorrelation 相邻输出的 lag-1 相关系数
+func SerialCorrelation(src rand.Source, n int) TestResult {
+	xs := make([]float64, n)
+	mean := 0.0
+	for i := range xs {
+		xs[i] = toUnit(src.Uint64())
+		mean += xs[i]
+	}
+	mean /= float64(n)
+	var num, den float64
+	for i, x := range xs {
+		d := x - mean
+		den += d * d
+		if i > 0 {
+			num += (xs[i-1] - mean) * d
+		}
+	}
+	r := num / den
+	z := r * math.Sqrt(float64(n))
+	return TestResult{Name: "serial-correlation", N: n, Statistic: r, PValue: normalTwoSided(z)}
+}
+
+// BirthdaySpacings 每轮取 m=512 个 24 位的生日（d=2^24 天，λ=2），共 trials 轮，
+// 重复间距总数服从 Poisson(trials·λ)
+func BirthdaySpacings(src rand.Source, trials int) TestResult {
+	const (
+		m        = 512
+		dayBits  = 24
+		lambda   = float64(m) * m * m / (4 << dayBits)
+		shiftOut = 64 - dayBits
+	)
+	days := make([]uint64, m)
+	spacings := make([]uint64, m)
+	total := 0
+	for t := 0; t < trials; t++ {
+		for i := range days {
+			days[i] = src.Uint64() >> shiftOut
+		}
+		slices.Sort(days)
+		spacings[0] = days[0]
+		for i := 1; i < m; i++ {
+			spacings[i] = days[i] - days[i-1]
+		}
+		slices.Sort(spacings)
+		for i := 1; i < m; i++ {
+			if spacings[i] == spacings[i-1] {
+				total++
+			}
+		}
+	}
+	mu := lambda * float64(trials)
+	return TestResult{Name: "birthday-spacings", N: trials * m, Statistic: float64(total), PValue: poissonTwoSided(total, mu)}
+}
+
+// poissonTwoSided X ~ Poisson(mu) 时观察到 k 的双侧 p 值
+func poissonTwoSided(k int, mu float64) float64 {
+	// P(X <= k) = Q(k+1, mu) = P(χ²(2k+2) >= 2mu)
+	cdf := mathsnippet.ChiSquareSurvival(2*mu, 2*(k+1))
+	sf := 1.0 // P(X >= k)
+	if k > 0 {
+		sf = 1 - mathsnippet.ChiSquareSurvival(2*mu, 2*k)
+	}
+	return min(1, 2*min(cdf, sf))
+}
+
+// RunAll 用默认参数跑全部检验，n 为各项的样本规模
+func RunAll(src rand.Source, n int) []TestResult {
+	return []TestResult{
+		ChiSquareUniform(Uniform(src, 1000), 1000, n),
+		Runs(src, n/64+1),
+		SerialCorrelation(src, n),
+		BirthdaySpacings(src, max(n/512, 10)),
+	}
+}
+
+// FormatResults 多行报告
+func FormatResults(results []TestResult) string {
+	lines := make([]string, len(results))
+	for i, r := range results {
+		lines[i] = r.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ModuloBias 把 width 位的均匀整数对 n 取模时，出现最多与最少的取值的概率之比减 1；0 表示无偏
+func ModuloBias(width uint, n uint64) float64 {
+	if width >= 64 {
+		rem := -n % n // 2^64 % n
+		if rem == 0 {
+			return 0
+		}
+		return 1 / float64(math.MaxUint64/n)
+	}
+	space := uint64(1) << width
+	switch {
+	case n > space:
+		return math.Inf(1) // 有的值永远取不到
+	case space%n == 0:
+		return 0
+	}
+	return 1 / float64(space/n)
+}
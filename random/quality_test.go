@@ -0,0 +1,118 @@
+package randomsnippet
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sourceFunc 把函数当作 rand.Source
+type sourceFunc func() uint64
+
+func (f sourceFunc) Uint64() uint64 { return f() }
+
+func TestQualityGoodSources(t *testing.T) {
+	for name, src := range map[string]rand.Source{
+		"PCG":          rand.NewPCG(1, 2),
+		"ChaCha8":      rand.NewChaCha8([32]byte{1}),
+		"crypto/rand":  NewReaderSource(crand.Reader),
+		"SecureReader": NewReaderSource(NewSecureReader(nil)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			results := RunAll(src, 200000)
+			if rs, ok := src.(*ReaderSource); ok && rs.Err() != nil {
+				t.Fatal(rs.Err())
+			}
+			t.Log("\n" + FormatResults(results))
+			for _, r := range results {
+				// crypto/rand 每次运行不同，阈值取得很小以免偶然失败
+				assert.False(t, r.Failed(1e-6), r.String())
+			}
+		})
+	}
+}
+
+func TestQualityBadSources(t *testing.T) {
+	failed := func(results []TestResult, name string) bool {
+		for _, r := range results {
+			if r.Name == name {
+				return r.Failed(1e-4)
+			}
+		}
+		return false
+	}
+
+	t.Run("乘数太小的线性同余：相邻输出相关", func(t *testing.T) {
+		x := uint64(1)
+		results := RunAll(sourceFunc(func() uint64 { x = x*5 + 1; return x }), 200000)
+		t.Log("\n" + FormatResults(results))
+		assert.True(t, failed(results, "serial-correlation"))
+	})
+
+	t.Run("RANDU：低位全为 0", func(t *testing.T) {
+		x := uint64(1)
+		results := RunAll(sourceFunc(func() uint64 { x = 65539 * x & (1<<31 - 1); return x << 33 }), 200000)
+		t.Log("\n" + FormatResults(results))
+		assert.True(t, failed(results, "runs"))
+	})
+
+	t.Run("比特偏向 1", func(t *testing.T) {
+		p := rand.NewPCG(1, 2)
+		results := RunAll(sourceFunc(func() uint64 { return p.Uint64() | p.Uint64() }), 200000)
+		t.Log("\n" + FormatResults(results))
+		assert.True(t, failed(results, "chi-square"))
+		assert.True(t, failed(results, "birthday-spacings"))
+	})
+}
+
+// random_seed_test.go 的 crypto_rand 子测试用 uint32 % 1000
+func TestQualityModuloBias(t *testing.T) {
+	t.Run("uint32 % 1000 有偏", func(t *testing.T) {
+		assert.InDelta(t, 1.0/4294967, ModuloBias(32, 1000), 1e-15)
+		assert.Zero(t, ModuloBias(32, 1024))
+		assert.Zero(t, ModuloBias(64, 1<<10))
+		assert.Greater(t, ModuloBias(64, 1000), 0.0)
+	})
+
+	// 同样的写法放到 16 位上，偏差约 1.5%，卡方检验可以发现
+	r := NewSecureReader(nil)
+	const n = 10000000
+
+	t.Run("uint16 % 1000 被卡方检验发现", func(t *testing.T) {
+		var buf [2]byte
+		next16 := func() uint64 {
+			if _, err := r.Read(buf[:]); err != nil {
+				t.Fatal(err)
+			}
+			return uint64(binary.BigEndian.Uint16(buf[:]))
+		}
+		res := ChiSquareUniform(func() int { return int(next16() % 1000) }, 1000, n)
+		t.Log(res)
+		assert.InDelta(t, 1.0/65, ModuloBias(16, 1000), 1e-12)
+		assert.True(t, res.Failed(1e-4))
+	})
+
+	t.Run("Uint64N 无偏", func(t *testing.T) {
+		src := NewReaderSource(r)
+		res := ChiSquareUniform(Uniform(src, 1000), 1000, n)
+		if err := src.Err(); err != nil {
+			t.Fatal(err)
+		}
+		t.Log(res)
+		assert.False(t, res.Failed(1e-6))
+	})
+}
+
+func TestReaderSourceError(t *testing.T) {
+	src := NewReaderSource(io.LimitReader(crand.Reader, 12))
+	assert.NotZero(t, src.Uint64())
+	assert.NoError(t, src.Err())
+	assert.Zero(t, src.Uint64(), "只剩 4 字节，读取失败")
+	assert.ErrorIs(t, src.Err(), io.ErrUnexpectedEOF)
+	assert.Zero(t, src.Uint64())
+	assert.ErrorIs(t, src.Err(), io.ErrUnexpectedEOF, "保留第一个错误")
+}
@@ -0,0 +1,117 @@
+package randtestsnippet
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+测试用的可复现随机数。random_seed_test.go 里的种子来自 time.Now().Unix()，出问题时既会重复又无法复现。
+单独成包是因为要引入 testing 并注册 -seed 参数，只应出现在测试代码的依赖里。
+
+	func TestXxx(t *testing.T) {
+		r := randtestsnippet.NewTestRand(t) // 失败时输出：random seed 12345 (replay: go test -run '^TestXxx$' -seed=12345)
+		...
+	}
+
+- 根种子：-seed 命令行参数 > 环境变量 TEST_SEED > crypto/rand 随机生成，整个测试进程共用一个
+- 每个测试（包括子测试）的随机流 = PCG(根种子, hash(测试名))：只由根种子和名字决定，
+  与测试执行顺序、是否并行、跑了哪些其它测试都无关，所以用 -run 只跑失败的那一个也能复现
+- 测试失败时在 Cleanup 里打印种子和复现命令；通过时不输出
+- -seed 只在测试二进制里注册（testing.Testing()），误被普通程序引入时也不会多出命令行参数
+*/
+
+// SeedEnv 指定根种子的环境变量
+const SeedEnv = "TEST_SEED"
+
+var seedFlag = func() *string {
+	if !testing.Testing() {
+		return nil
+	}
+	return flag.String("seed", "", "root seed for NewTestRand, overrides $"+SeedEnv)
+}()
+
+var rootSeed struct {
+	once   sync.Once
+	seed   uint64
+	source string
+	err    error
+}
+
+// parseSeed 按 -seed、环境变量、随机的优先级得到根种子，source 说明种子的来源
+func parseSeed(flagValue, envValue string) (seed uint64, source string, err error) {
+	switch {
+	case flagValue != "":
+		seed, err = strconv.ParseUint(flagValue, 10, 64)
+		return seed, "-seed", err
+	case envValue != "":
+		seed, err = strconv.ParseUint(envValue, 10, 64)
+		return seed, "$" + SeedEnv, err
+	}
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return 0, "", err
+	}
+	return binary.LittleEndian.Uint64(b[:]), "random", nil
+}
+
+// RootSeed 本次测试进程的根种子
+func RootSeed() (uint64, error) {
+	rootSeed.once.Do(func() {
+		flagValue := ""
+		if seedFlag != nil {
+			flagValue = *seedFlag
+		}
+		rootSeed.seed, rootSeed.source, rootSeed.err = parseSeed(flagValue, os.Getenv(SeedEnv))
+		if rootSeed.err != nil {
+			rootSeed.err = fmt.Errorf("invalid %s seed: %w", rootSeed.source, rootSeed.err)
+		}
+	})
+	return rootSeed.seed, rootSeed.err
+}
+
+// streamSeed 测试名对应的随机流编号
+func streamSeed(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()
+}
+
+// NewTestRand 返回当前测试专用的确定性随机数生成器，测试失败时打印种子和复现命令
+func NewTestRand(tb testing.TB) *rand.Rand {
+	tb.Helper()
+	seed, err := RootSeed()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return newTestRand(tb, seed)
+}
+
+func newTestRand(tb testing.TB, seed uint64) *rand.Rand {
+	name := tb.Name()
+	tb.Cleanup(func() {
+		if tb.Failed() {
+			tb.Logf("random seed %d (replay: go test -run '%s' -seed=%d)", seed, runPattern(name), seed)
+		}
+	})
+	return rand.New(rand.NewPCG(seed, streamSeed(name)))
+}
+
+// runPattern 精确匹配 name 的 -run 参数，子测试的每一级单独锚定
+func runPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(p) + "$"
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,103 @@
+package randtestsnippet
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	randomsnippet "github.com/A0dongq1N/golang_snippet/random"
+)
+
+// fakeTB 记录 Cleanup 和日志，用来模拟失败的测试
+type fakeTB struct {
+	testing.TB
+	name     string
+	failed   bool
+	logs     []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Name() string      { return f.name }
+func (f *fakeTB) Failed() bool      { return f.failed }
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Logf(format string, args ...any) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+// finish 模拟测试结束，执行 Cleanup
+func (f *fakeTB) finish() {
+	for _, fn := range f.cleanups {
+		fn()
+	}
+}
+
+func draw(tb testing.TB, seed uint64, n int) []int { return newTestRand(tb, seed).Perm(n) }
+
+func TestParseSeed(t *testing.T) {
+	seed, source, err := parseSeed("42", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), seed)
+	assert.Equal(t, "-seed", source)
+
+	seed, source, err = parseSeed("", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), seed)
+	assert.Equal(t, "$TEST_SEED", source)
+
+	_, source, err = parseSeed("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "random", source)
+
+	_, _, err = parseSeed("abc", "")
+	assert.Error(t, err)
+}
+
+func TestNewTestRand(t *testing.T) {
+	t.Run("同一种子同一测试名可复现", func(t *testing.T) {
+		a := draw(&fakeTB{name: "TestX/case"}, 42, 10)
+		b := draw(&fakeTB{name: "TestX/case"}, 42, 10)
+		assert.Equal(t, a, b)
+	})
+
+	t.Run("子测试之间相互独立", func(t *testing.T) {
+		a := draw(&fakeTB{name: "TestX/a"}, 42, 10)
+		b := draw(&fakeTB{name: "TestX/b"}, 42, 10)
+		assert.NotEqual(t, a, b)
+		assert.NotEqual(t, a, draw(&fakeTB{name: "TestX/a"}, 43, 10), "换种子结果不同")
+	})
+
+	t.Run("失败时打印种子和复现命令", func(t *testing.T) {
+		tb := &fakeTB{name: "TestX/bad case"}
+		newTestRand(tb, 42)
+		tb.failed = true
+		tb.finish()
+		assert.Equal(t, []string{"random seed 42 (replay: go test -run '^TestX$/^bad case$' -seed=42)"}, tb.logs)
+	})
+
+	t.Run("通过时不输出", func(t *testing.T) {
+		tb := &fakeTB{name: "TestX"}
+		newTestRand(tb, 42)
+		tb.finish()
+		assert.Empty(t, tb.logs)
+	})
+
+	t.Run("真实测试里使用", func(t *testing.T) {
+		// 用 -seed=N 或 TEST_SEED=N 固定，否则每次运行不同，失败时会打印出来
+		r := NewTestRand(t)
+		weights := make([]float64, 10)
+		for i := range weights {
+			weights[i] = float64(r.IntN(5))
+		}
+		weights[r.IntN(len(weights))] = 1 // 至少一个正权重
+		order, err := randomsnippet.WeightedShuffle(weights, r)
+		assert.NoError(t, err)
+		assert.Len(t, order, len(weights))
+		// 权重为 0 的都排在正权重之后
+		for i := 1; i < len(order); i++ {
+			if weights[order[i]] > 0 {
+				assert.Positive(t, weights[order[i-1]], "order=%v weights=%v", order, weights)
+			}
+		}
+	})
+}
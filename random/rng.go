@@ -0,0 +1,185 @@
+package randomsnippet
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"math/rand/v2"
+	"sync"
+)
+
+/*
+替代全局 math/rand 的并发安全随机数。random_seed_test.go 里几种做法的问题：
+
+- 全局 rand.Intn：Go 1.20 前需要自己 Seed，多个 goroutine 同一秒 Seed 得到相同序列；全局源内部加锁，高并发下争用
+- 每个 goroutine 新建 rand.NewSource：每次创建要初始化 4.9KB 的状态，种子来自时间时仍可能相同
+- 互斥锁保护一个源：正确，但所有 goroutine 串行在一把锁上
+- crypto/rand 每次读 4 字节：每次都是一次系统调用级别的开销；`uint32 % 1000` 还有取模偏差
+
+这里提供三样东西：
+
+- Sharded：每个 P 一个 PCG/ChaCha8 生成器（通过 sync.Pool 实现，Pool 内部就是按 P 分片的无锁缓存），
+  种子来自 crypto/rand，互不相同；不可复现，需要复现时用 rand.New(rand.NewPCG(seed, seq))。
+  只是要一个随机数时 math/rand/v2 的顶层函数（runtime 内部每个 M 一个 ChaCha8）更快，
+  Sharded 用于需要一个可传递的实例、或要换成 PCG 等其它随机源的场景
+- SecureReader：带缓冲的 crypto/rand，一次读 4KB 再分给小的读取，用于 token、nonce 等需要不可预测的值
+- Uint64N/IntN：Lemire 的无偏有界随机数（乘法 + 拒绝），不用除法，大多数情况下一次乘法就够
+
+	x % n 的偏差：2^32 不是 1000 的整数倍，0..295 出现的概率比 296..999 高 1/4294967
+	n 越大越明显：n = 2^64 × 2/3 时，x % n 落在 [0, n/2) 的概率是 2/3 而不是 1/2
+*/
+
+// Uint64N 返回 [0, n) 上均匀分布的整数，next 提供均匀的 64 位随机数；n == 0 时 panic
+func Uint64N(next func() uint64, n uint64) uint64 {
+	if n == 0 {
+		panic("randomsnippet: Uint64N with n == 0")
+	}
+	// x*n 的高 64 位落在 [0, n)，低 64 位小于 2^64 % n 时说明落在了多出来的那一段，拒绝重来
+	hi, lo := bits.Mul64(next(), n)
+	if lo < n {
+		threshold := -n % n // 2^64 % n
+		for lo < threshold {
+			hi, lo = bits.Mul64(next(), n)
+		}
+	}
+	return hi
+}
+
+// IntN 返回 [0, n) 上均匀分布的整数，n <= 0 时 panic
+func IntN(next func() uint64, n int) int {
+	if n <= 0 {
+		panic("randomsnippet: IntN with n <= 0")
+	}
+	return int(Uint64N(next, uint64(n)))
+}
+
+// Sharded 并发安全的伪随机数生成器，每个 P 一个随机源
+type Sharded struct {
+	pool sync.Pool
+}
+
+// NewSharded 创建，newSource 为每个分片创建随机源，为空时使用 crypto/rand 做种子的 ChaCha8
+func NewSharded(newSource func() rand.Source) *Sharded {
+	if newSource == nil {
+		newSource = newChaCha8
+	}
+	s := &Sharded{}
+	s.pool.New = func() any { return rand.New(newSource()) }
+	return s
+}
+
+func newChaCha8() rand.Source {
+	var seed [32]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		panic(err)
+	}
+	return rand.NewChaCha8(seed)
+}
+
+// NewPCGSource 用 crypto/rand 做种子的 PCG，比 ChaCha8 快但不具备密码学强度，可作为 NewSharded 的参数
+func NewPCGSource() rand.Source {
+	var seed [16]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		panic(err)
+	}
+	return rand.NewPCG(binary.LittleEndian.Uint64(seed[:8]), binary.LittleEndian.Uint64(seed[8:]))
+}
+
+func (s *Sharded) get() *rand.Rand  { return s.pool.Get().(*rand.Rand) }
+func (s *Sharded) put(r *rand.Rand) { s.pool.Put(r) }
+
+// Uint64 均匀的 64 位随机数
+func (s *Sharded) Uint64() uint64 {
+	r := s.get()
+	v := r.Uint64()
+	s.put(r)
+	return v
+}
+
+// IntN [0, n) 上的均匀整数
+func (s *Sharded) IntN(n int) int {
+	r := s.get()
+	v := r.IntN(n)
+	s.put(r)
+	return v
+}
+
+// Float64 [0, 1) 上的均匀浮点数
+func (s *Sharded) Float64() float64 {
+	r := s.get()
+	v := r.Float64()
+	s.put(r)
+	return v
+}
+
+// Shuffle 随机打乱
+func (s *Sharded) Shuffle(n int, swap func(i, j int)) {
+	r := s.get()
+	r.Shuffle(n, swap)
+	s.put(r)
+}
+
+// Perm [0, n) 的随机排列
+func (s *Sharded) Perm(n int) []int {
+	r := s.get()
+	p := r.Perm(n)
+	s.put(r)
+	return p
+}
+
+// SecureReader 带缓冲的 crypto/rand，并发安全
+type SecureReader struct {
+	mu  sync.Mutex
+	src io.Reader
+	buf [4096]byte
+	off int // buf[off:] 未使用
+}
+
+// NewSecureReader 创建，src 为空时使用 crypto/rand.Reader
+func NewSecureReader(src io.Reader) *SecureReader {
+	if src == nil {
+		src = crand.Reader
+	}
+	r := &SecureReader{src: src}
+	r.off = len(r.buf)
+	return r
+}
+
+// Read 实现 io.Reader，大于缓冲区的读取直接读底层
+func (r *SecureReader) Read(p []byte) (int, error) {
+	if len(p) >= len(r.buf) {
+		return io.ReadFull(r.src, p)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for n < len(p) {
+		if r.off == len(r.buf) {
+			if _, err := io.ReadFull(r.src, r.buf[:]); err != nil {
+				return n, err
+			}
+			r.off = 0
+		}
+		c := copy(p[n:], r.buf[r.off:])
+		// 用过的字节清零，缓冲区里只留未用的随机数
+		clear(r.buf[r.off : r.off+c])
+		r.off += c
+		n += c
+	}
+	return n, nil
+}
+
+// Uint64 安全的 64 位随机数，底层读取失败时 panic
+func (r *SecureReader) Uint64() uint64 {
+	var b [8]byte
+	if _, err := r.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// IntN 安全的 [0, n) 上的均匀整数
+func (r *SecureReader) IntN(n int) int {
+	return IntN(r.Uint64, n)
+}
@@ -0,0 +1,219 @@
+package randomsnippet
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+	mrand "math/rand"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUint64N(t *testing.T) {
+	t.Run("比取模无偏", func(t *testing.T) {
+		// n = 2^64 × 2/3：x % n 落在前一半的概率是 2/3，Uint64N 是 1/2
+		n := uint64(math.MaxUint64 / 3 * 2)
+		r := rand.New(rand.NewPCG(1, 2))
+		const draws = 100000
+		modulo, lemire := 0, 0
+		for i := 0; i < draws; i++ {
+			if r.Uint64()%n < n/2 {
+				modulo++
+			}
+			if Uint64N(r.Uint64, n) < n/2 {
+				lemire++
+			}
+		}
+		t.Logf("modulo=%.4f lemire=%.4f", float64(modulo)/draws, float64(lemire)/draws)
+		assert.InDelta(t, 2.0/3, float64(modulo)/draws, 0.01)
+		assert.InDelta(t, 0.5, float64(lemire)/draws, 0.01)
+	})
+
+	t.Run("拒绝落在多余区间的值", func(t *testing.T) {
+		// n = 2^63+1 时 threshold = 2^64 % n = 2^63-1：
+		// x=0 的低 64 位为 0 < threshold，拒绝；x=1 的低 64 位为 n >= threshold，接受
+		n := uint64(1<<63 + 1)
+		seq := []uint64{0, 0, 1}
+		calls := 0
+		next := func() uint64 { v := seq[calls]; calls++; return v }
+		assert.Equal(t, uint64(0), Uint64N(next, n))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("范围", func(t *testing.T) {
+		r := rand.New(rand.NewPCG(3, 4))
+		counts := make([]int, 7)
+		for i := 0; i < 70000; i++ {
+			counts[IntN(r.Uint64, 7)]++
+		}
+		for _, c := range counts {
+			assert.InDelta(t, 10000, c, 500)
+		}
+		assert.Equal(t, uint64(0), Uint64N(r.Uint64, 1))
+		assert.Panics(t, func() { IntN(r.Uint64, 0) })
+	})
+}
+
+func TestSharded(t *testing.T) {
+	for name, newSource := range map[string]func() rand.Source{"ChaCha8": nil, "PCG": NewPCGSource} {
+		t.Run(name, func(t *testing.T) { testSharded(t, NewSharded(newSource)) })
+	}
+}
+
+func testSharded(t *testing.T, s *Sharded) {
+	var wg sync.WaitGroup
+	seen := sync.Map{}
+	var dup atomic.Int64
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if _, loaded := seen.LoadOrStore(s.Uint64(), struct{}{}); loaded {
+					dup.Add(1)
+				}
+				v := s.IntN(10)
+				assert.True(t, v >= 0 && v < 10)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Zero(t, dup.Load(), "各分片种子不同，不会产生重复序列")
+	assert.Len(t, s.Perm(5), 5)
+	f := s.Float64()
+	assert.True(t, f >= 0 && f < 1)
+}
+
+func TestSecureReader(t *testing.T) {
+	t.Run("小读取分摊一次底层读取", func(t *testing.T) {
+		src := &countingReader{r: rand.NewChaCha8([32]byte{1})}
+		r := NewSecureReader(src)
+		for i := 0; i < 512; i++ {
+			r.Uint64()
+		}
+		assert.Equal(t, 1, src.calls, "512 × 8 字节正好一个缓冲区")
+		r.Uint64()
+		assert.Equal(t, 2, src.calls)
+	})
+
+	t.Run("跨缓冲区和大读取", func(t *testing.T) {
+		seed := [32]byte{2}
+		want := make([]byte, 3*4096)
+		rand.NewChaCha8(seed).Read(want)
+
+		r := NewSecureReader(rand.NewChaCha8(seed))
+		got := make([]byte, 0, len(want))
+		buf := make([]byte, 1000)
+		for len(got) < 2*4096 {
+			n, err := r.Read(buf[:min(len(buf), 2*4096-len(got))])
+			assert.NoError(t, err)
+			got = append(got, buf[:n]...)
+		}
+		big := make([]byte, 4096)
+		_, err := r.Read(big)
+		assert.NoError(t, err)
+		got = append(got, big...)
+		assert.True(t, bytes.Equal(want, got))
+	})
+
+	t.Run("默认使用 crypto/rand", func(t *testing.T) {
+		r := NewSecureReader(nil)
+		v := r.IntN(1000)
+		assert.True(t, v >= 0 && v < 1000)
+	})
+}
+
+type countingReader struct {
+	r     *rand.ChaCha8
+	calls int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.calls++
+	return c.r.Read(p)
+}
+
+// BenchmarkRand 对应 TestRandomConcurrency 里的几种方案，都是并发调用 IntN(1000)
+//
+//	go test -bench=BenchmarkRand -cpu=1,8 ./random
+func BenchmarkRand(b *testing.B) {
+	b.Run("GlobalV1", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = mrand.Intn(1000)
+			}
+		})
+	})
+
+	b.Run("PerGoroutineSource", func(b *testing.B) {
+		var seq atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			r := mrand.New(mrand.NewSource(seq.Add(1)))
+			for pb.Next() {
+				_ = r.Intn(1000)
+			}
+		})
+	})
+
+	b.Run("MutexV1", func(b *testing.B) {
+		var mu sync.Mutex
+		r := mrand.New(mrand.NewSource(1))
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				mu.Lock()
+				_ = r.Intn(1000)
+				mu.Unlock()
+			}
+		})
+	})
+
+	b.Run("CryptoRand", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			buf := make([]byte, 4)
+			for pb.Next() {
+				crand.Read(buf)
+				_ = binary.BigEndian.Uint32(buf) % 1000
+			}
+		})
+	})
+
+	b.Run("GlobalV2", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = rand.IntN(1000)
+			}
+		})
+	})
+
+	b.Run("ShardedChaCha8", func(b *testing.B) {
+		s := NewSharded(nil)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = s.IntN(1000)
+			}
+		})
+	})
+
+	b.Run("ShardedPCG", func(b *testing.B) {
+		s := NewSharded(NewPCGSource)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = s.IntN(1000)
+			}
+		})
+	})
+
+	b.Run("SecureReader", func(b *testing.B) {
+		r := NewSecureReader(nil)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = r.IntN(1000)
+			}
+		})
+	})
+}
@@ -0,0 +1,248 @@
+package randomsnippet
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+/*
+加权随机抽样，所有结构都通过 rand.Source 注入随机源，测试时传固定种子即可复现。
+它们都不是并发安全的（rand.Rand 不是），每个 goroutine 各用一个，或外面加锁。
+
+- Alias（Vose 别名法）：O(n) 预处理，之后每次抽样 O(1)，适合权重固定、抽样次数很多的场景。
+  把 n 个权重归一化到平均值 1，拆成 n 个高度为 1 的桶，每个桶最多放两个元素（自己 + 一个“别名”）：
+  抽样时均匀选一个桶，再抛一次硬币决定取桶的主人还是别名
+- Reservoir（加权蓄水池，Efraimidis-Spirakis）：从长度未知的流中按权重无放回地选 k 个，内存 O(k)。
+  A-Res：每个元素取 key = u^(1/w)，保留 key 最大的 k 个；
+  A-ExpJ：同样的分布，但按指数分布算出下一次替换前可以跳过的总权重，随机数调用次数从 O(n) 降到 O(k·log(n/k))
+- WeightedShuffle：加权无放回的全排列，等价于依次按剩余权重抽取；
+  实现上同样是 key = u^(1/w) 降序排序，O(n log n)
+
+key 都在对数空间计算（log(u)/w），避免权重很大时 u^(1/w) 下溢或全部接近 1。
+*/
+
+// ErrInvalidWeights 权重为空、含负数/NaN/Inf 或全部为 0
+var ErrInvalidWeights = errors.New("randomsnippet: invalid weights")
+
+func newRand(src rand.Source) *rand.Rand {
+	if src == nil {
+		src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	return rand.New(src)
+}
+
+func checkWeights(weights []float64) error {
+	total := 0.0
+	for _, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return ErrInvalidWeights
+		}
+		total += w
+	}
+	if total <= 0 || math.IsInf(total, 0) {
+		return ErrInvalidWeights
+	}
+	return nil
+}
+
+// uniformOpen (0, 1] 上的均匀分布，避免 log(0)
+func uniformOpen(r *rand.Rand) float64 {
+	return 1 - r.Float64()
+}
+
+// Alias Vose 别名法抽样器
+type Alias struct {
+	prob  []float64 // 桶 i 取自己的概率
+	alias []int     // 桶 i 的别名
+	rand  *rand.Rand
+}
+
+// NewAlias 按 weights 构造，src 为空时使用随机种子
+func NewAlias(weights []float64, src rand.Source) (*Alias, error) {
+	if err := checkWeights(weights); err != nil {
+		return nil, err
+	}
+	n := len(weights)
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	a := &Alias{prob: make([]float64, n), alias: make([]int, n), rand: newRand(src)}
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / total
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		a.prob[s], a.alias[s] = scaled[s], l
+		// 大的那个补满 s 的桶后剩余的高度
+		scaled[l] -= 1 - scaled[s]
+		if scaled[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// 剩下的只差浮点误差，按 1 处理
+	for _, i := range large {
+		a.prob[i] = 1
+	}
+	for _, i := range small {
+		a.prob[i] = 1
+	}
+	return a, nil
+}
+
+// Sample 按权重返回一个下标，O(1)
+func (a *Alias) Sample() int {
+	i := a.rand.IntN(len(a.prob))
+	if a.rand.Float64() < a.prob[i] {
+		return i
+	}
+	return a.alias[i]
+}
+
+// Len 元素个数
+func (a *Alias) Len() int { return len(a.prob) }
+
+type keyed[T any] struct {
+	item T
+	key  float64 // log(u)/w，越大越优先
+}
+
+// minHeap 按 key 的小顶堆，堆顶是蓄水池里最容易被替换的元素
+type minHeap[T any] []keyed[T]
+
+func (h minHeap[T]) Len() int           { return len(h) }
+func (h minHeap[T]) Less(i, j int) bool { return h[i].key < h[j].key }
+func (h minHeap[T]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap[T]) Push(x any)        { *h = append(*h, x.(keyed[T])) }
+func (h *minHeap[T]) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Reservoir 加权蓄水池抽样
+type Reservoir[T any] struct {
+	k    int
+	expJ bool
+	heap minHeap[T]
+	rand *rand.Rand
+
+	skip float64 // A-ExpJ：下次替换前还要跳过的权重
+	seen int
+}
+
+// NewReservoir 使用 A-ExpJ，从流中按权重无放回地选 k 个
+func NewReservoir[T any](k int, src rand.Source) *Reservoir[T] {
+	return &Reservoir[T]{k: k, expJ: true, rand: newRand(src)}
+}
+
+// NewReservoirARes 使用 A-Res，每个元素都要一次随机数，结果分布与 NewReservoir 相同
+func NewReservoirARes[T any](k int, src rand.Source) *Reservoir[T] {
+	return &Reservoir[T]{k: k, rand: newRand(src)}
+}
+
+// Add 加入一个元素，weight <= 0 的元素永远不会被选中
+func (r *Reservoir[T]) Add(item T, weight float64) {
+	r.seen++
+	if weight <= 0 || r.k <= 0 {
+		return
+	}
+	if len(r.heap) < r.k {
+		heap.Push(&r.heap, keyed[T]{item, math.Log(uniformOpen(r.rand)) / weight})
+		if len(r.heap) == r.k && r.expJ {
+			r.resetSkip()
+		}
+		return
+	}
+
+	if !r.expJ {
+		key := math.Log(uniformOpen(r.rand)) / weight
+		if key > r.heap[0].key {
+			r.heap[0] = keyed[T]{item, key}
+			heap.Fix(&r.heap, 0)
+		}
+		return
+	}
+
+	r.skip -= weight
+	if r.skip > 0 {
+		return
+	}
+	// 该元素替换堆顶：key 在 (T^w, 1] 上均匀分布后开 w 次方，对数空间里即 log(uniform(T^w, 1))/w
+	tw := math.Exp(r.heap[0].key * weight)
+	u := tw + (1-tw)*uniformOpen(r.rand)
+	r.heap[0] = keyed[T]{item, math.Log(u) / weight}
+	heap.Fix(&r.heap, 0)
+	r.resetSkip()
+}
+
+// resetSkip 下一次替换前跳过的权重 X = log(u)/log(T)，T 为当前最小 key
+func (r *Reservoir[T]) resetSkip() {
+	r.skip = math.Log(uniformOpen(r.rand)) / r.heap[0].key
+}
+
+// Items 当前选中的元素，按 key 从大到小（即大致按被抽中的先后）
+func (r *Reservoir[T]) Items() []T {
+	sorted := slices.Clone(r.heap)
+	slices.SortFunc(sorted, func(a, b keyed[T]) int { return cmp.Compare(b.key, a.key) })
+	out := make([]T, len(sorted))
+	for i, kv := range sorted {
+		out[i] = kv.item
+	}
+	return out
+}
+
+// Seen 已经加入的元素个数
+func (r *Reservoir[T]) Seen() int { return r.seen }
+
+// WeightedShuffle 加权无放回的随机排列，返回下标；权重为 0 的排在最后，保持原顺序
+func WeightedShuffle(weights []float64, src rand.Source) ([]int, error) {
+	if err := checkWeights(weights); err != nil {
+		return nil, err
+	}
+	r := newRand(src)
+	keys := make([]float64, len(weights))
+	order := make([]int, len(weights))
+	for i, w := range weights {
+		order[i] = i
+		keys[i] = math.Inf(-1)
+		if w > 0 {
+			keys[i] = math.Log(uniformOpen(r)) / w
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(keys[b], keys[a]) })
+	return order, nil
+}
+
+// WeightedSample 加权无放回地选 k 个下标，k 超过正权重个数时只返回正权重的元素，k <= 0 时返回空
+func WeightedSample(weights []float64, k int, src rand.Source) ([]int, error) {
+	order, err := WeightedShuffle(weights, src)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return order[:0], nil
+	}
+	positive := 0
+	for _, w := range weights {
+		if w > 0 {
+			positive++
+		}
+	}
+	return order[:min(k, positive)], nil
+}
@@ -0,0 +1,166 @@
+package randomsnippet
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// shares 各下标出现的比例
+func shares(counts []int) []float64 {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	out := make([]float64, len(counts))
+	for i, c := range counts {
+		out[i] = float64(c) / float64(total)
+	}
+	return out
+}
+
+func TestAlias(t *testing.T) {
+	weights := []float64{5, 2, 3, 0, 10}
+
+	t.Run("按权重分布", func(t *testing.T) {
+		a, err := NewAlias(weights, rand.NewPCG(1, 2))
+		assert.NoError(t, err)
+		counts := make([]int, a.Len())
+		for i := 0; i < 200000; i++ {
+			counts[a.Sample()]++
+		}
+		t.Log(counts)
+		assert.Zero(t, counts[3], "权重为 0 的不会被选中")
+		for i, s := range shares(counts) {
+			assert.InDelta(t, weights[i]/20, s, 0.005, "index %d", i)
+		}
+	})
+
+	t.Run("相同种子相同序列", func(t *testing.T) {
+		a1, _ := NewAlias(weights, rand.NewPCG(7, 7))
+		a2, _ := NewAlias(weights, rand.NewPCG(7, 7))
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, a1.Sample(), a2.Sample())
+		}
+	})
+
+	t.Run("非法权重", func(t *testing.T) {
+		for _, w := range [][]float64{nil, {0, 0}, {1, -1}} {
+			_, err := NewAlias(w, nil)
+			assert.ErrorIs(t, err, ErrInvalidWeights)
+		}
+	})
+}
+
+func TestReservoir(t *testing.T) {
+	weights := []float64{1, 2, 3, 4, 0, 10}
+
+	// 重复抽样，统计每个元素被选中的比例
+	inclusion := func(newR func(seed uint64) *Reservoir[int], rounds int) []float64 {
+		counts := make([]int, len(weights))
+		for round := 0; round < rounds; round++ {
+			r := newR(uint64(round))
+			for i, w := range weights {
+				r.Add(i, w)
+			}
+			for _, i := range r.Items() {
+				counts[i]++
+			}
+		}
+		out := make([]float64, len(counts))
+		for i, c := range counts {
+			out[i] = float64(c) / float64(rounds)
+		}
+		return out
+	}
+
+	t.Run("k=1 时按权重选中", func(t *testing.T) {
+		for name, newR := range map[string]func(uint64) *Reservoir[int]{
+			"A-Res":  func(seed uint64) *Reservoir[int] { return NewReservoirARes[int](1, rand.NewPCG(seed, 1)) },
+			"A-ExpJ": func(seed uint64) *Reservoir[int] { return NewReservoir[int](1, rand.NewPCG(seed, 1)) },
+		} {
+			got := inclusion(newR, 50000)
+			t.Log(name, got)
+			for i, p := range got {
+				assert.InDelta(t, weights[i]/20, p, 0.01, "%s index %d", name, i)
+			}
+		}
+	})
+
+	t.Run("k=3 时两种算法分布相同", func(t *testing.T) {
+		ares := inclusion(func(seed uint64) *Reservoir[int] { return NewReservoirARes[int](3, rand.NewPCG(seed, 1)) }, 50000)
+		expj := inclusion(func(seed uint64) *Reservoir[int] { return NewReservoir[int](3, rand.NewPCG(seed, 2)) }, 50000)
+		t.Log(ares, expj)
+		assert.InDeltaSlice(t, ares, expj, 0.015)
+		assert.Zero(t, ares[4])
+		assert.Zero(t, expj[4])
+		assert.Greater(t, ares[5], 0.9)
+	})
+
+	t.Run("长流只保留 k 个", func(t *testing.T) {
+		r := NewReservoir[int](10, rand.NewPCG(1, 1))
+		for i := 0; i < 100000; i++ {
+			r.Add(i, float64(i%7+1))
+		}
+		assert.Len(t, r.Items(), 10)
+		assert.Equal(t, 100000, r.Seen())
+	})
+}
+
+func TestWeightedShuffle(t *testing.T) {
+	weights := []float64{1, 0, 3, 6}
+
+	t.Run("是排列且权重 0 在最后", func(t *testing.T) {
+		order, err := WeightedShuffle(weights, rand.NewPCG(1, 2))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, order[3])
+		sorted := slices.Clone(order)
+		slices.Sort(sorted)
+		assert.Equal(t, []int{0, 1, 2, 3}, sorted)
+	})
+
+	t.Run("第一个按权重分布", func(t *testing.T) {
+		src := rand.NewPCG(3, 4)
+		counts := make([]int, len(weights))
+		for i := 0; i < 100000; i++ {
+			order, _ := WeightedShuffle(weights, src)
+			counts[order[0]]++
+		}
+		for i, s := range shares(counts) {
+			assert.InDelta(t, weights[i]/10, s, 0.01, "index %d", i)
+		}
+	})
+
+	t.Run("第二个按剩余权重分布", func(t *testing.T) {
+		// 第一个是 3（权重 6）时，第二个是 2 的概率为 3/(1+3)
+		src := rand.NewPCG(5, 6)
+		first, second := 0, 0
+		for i := 0; i < 100000; i++ {
+			order, _ := WeightedShuffle(weights, src)
+			if order[0] == 3 {
+				first++
+				if order[1] == 2 {
+					second++
+				}
+			}
+		}
+		assert.InDelta(t, 0.75, float64(second)/float64(first), 0.01)
+	})
+
+	t.Run("WeightedSample", func(t *testing.T) {
+		got, err := WeightedSample(weights, 10, rand.NewPCG(1, 2))
+		assert.NoError(t, err)
+		assert.Len(t, got, 3, "只有 3 个正权重")
+		assert.NotContains(t, got, 1)
+	})
+
+	t.Run("WeightedSample k<=0时返回空", func(t *testing.T) {
+		for _, k := range []int{0, -1} {
+			got, err := WeightedSample(weights, k, rand.NewPCG(1, 2))
+			assert.NoError(t, err)
+			assert.Empty(t, got)
+		}
+	})
+}
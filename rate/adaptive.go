@@ -0,0 +1,293 @@
+package ratesnippet
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+自适应并发限流：固定 QPS（rate.NewLimiter(100, 10)）在下游变慢时保护不了它——
+每秒请求数没变，但每个请求占用的时间变长，在途请求越积越多。
+按 Little 定律：并发 = 吞吐 × 延迟，所以限制“在途请求数”，并根据观测到的延迟和错误动态调整上限。
+
+	tok, err := limiter.Acquire(ctx) // 超过上限时排队，ctx 结束则放弃
+	err = call()
+	tok.Release(outcome)             // 上报结果（成功/丢弃/忽略）和耗时，策略据此调整上限
+
+三种调整策略：
+- AIMD：成功时 +1，超时/丢弃时乘以 BackoffRatio。简单，只对错误敏感
+- Vegas：用最小 RTT 估计无负载延迟，排队长度 = limit × (1 - minRTT/rtt)，排队少就加、排队多就减
+- Gradient：最小 RTT 与本次 RTT 的比值作为梯度，梯度 < 1 说明在变慢，按比例收缩；梯度为 1 时按 sqrt(limit) 试探扩容
+*/
+
+// Outcome 一次调用的结果
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota // 成功
+	OutcomeDropped                // 超时、过载等说明下游扛不住的错误，触发收缩
+	OutcomeIgnore                 // 与下游负载无关的失败（参数错误、ctx 取消），不参与调整
+)
+
+// Sample 一次调用的观测值
+type Sample struct {
+	RTT      time.Duration
+	InFlight int // 本次调用开始时的在途请求数（含自身）
+	Dropped  bool
+}
+
+// LimitStrategy 根据观测值计算新的并发上限，由 AdaptiveLimiter 在锁内调用，实现无需并发安全
+type LimitStrategy interface {
+	Update(s Sample, limit int) int
+}
+
+func clampLimit(limit, minLimit, maxLimit int) int {
+	if minLimit <= 0 {
+		minLimit = 1
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		return maxLimit
+	}
+	if limit < minLimit {
+		return minLimit
+	}
+	return limit
+}
+
+// AIMD 加性增、乘性减
+type AIMD struct {
+	MinLimit     int
+	MaxLimit     int
+	BackoffRatio float64       // 收缩比例，默认 0.9
+	Timeout      time.Duration // RTT 超过它也视为丢弃，0 表示只看 Outcome
+}
+
+func (a *AIMD) Update(s Sample, limit int) int {
+	ratio := a.BackoffRatio
+	if ratio <= 0 || ratio >= 1 {
+		ratio = 0.9
+	}
+	switch {
+	case s.Dropped || (a.Timeout > 0 && s.RTT > a.Timeout):
+		limit = int(float64(limit) * ratio)
+	case s.InFlight*2 >= limit:
+		// 只有真的用到了一半以上的额度才扩容，避免空闲时无限增长
+		limit++
+	}
+	return clampLimit(limit, a.MinLimit, a.MaxLimit)
+}
+
+// Vegas 基于排队延迟的调整，来自 TCP Vegas
+type Vegas struct {
+	MinLimit int
+	MaxLimit int
+	Alpha    int // 估算排队数小于它时扩容，默认 3
+	Beta     int // 估算排队数大于它时收缩，默认 6
+
+	rttNoLoad time.Duration
+}
+
+func (v *Vegas) Update(s Sample, limit int) int {
+	alpha, beta := v.Alpha, v.Beta
+	if alpha <= 0 {
+		alpha = 3
+	}
+	if beta <= alpha {
+		beta = 2 * alpha
+	}
+	if s.RTT > 0 && (v.rttNoLoad == 0 || s.RTT < v.rttNoLoad) {
+		v.rttNoLoad = s.RTT
+	}
+	if s.Dropped {
+		return clampLimit(limit-max(limit/4, 1), v.MinLimit, v.MaxLimit)
+	}
+	if s.RTT <= 0 || s.InFlight*2 < limit {
+		return clampLimit(limit, v.MinLimit, v.MaxLimit)
+	}
+
+	queue := int(math.Ceil(float64(limit) * (1 - float64(v.rttNoLoad)/float64(s.RTT))))
+	switch {
+	case queue < alpha:
+		limit++
+	case queue > beta:
+		limit--
+	}
+	return clampLimit(limit, v.MinLimit, v.MaxLimit)
+}
+
+// Gradient 基于 RTT 梯度的调整（参考 Netflix concurrency-limits 的 GradientLimit）。
+// 基线取观测到的最小 RTT：用 EWMA 做基线时，延迟缓慢上升会被基线一路跟上，上限永远不会收缩
+type Gradient struct {
+	MinLimit  int
+	MaxLimit  int
+	Tolerance float64 // 允许本次 RTT 比基线高多少倍而不收缩，默认 1.5
+	Smoothing float64 // 新上限的平滑系数，默认 0.2
+
+	minRTT time.Duration
+	limit  float64 // 保留小数部分，避免平滑后总被取整抹掉
+}
+
+func (g *Gradient) Update(s Sample, limit int) int {
+	tolerance, smoothing := g.Tolerance, g.Smoothing
+	if tolerance <= 0 {
+		tolerance = 1.5
+	}
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if g.limit == 0 || int(g.limit) != limit {
+		g.limit = float64(limit)
+	}
+	if s.RTT <= 0 {
+		return limit
+	}
+	if g.minRTT == 0 || s.RTT < g.minRTT {
+		g.minRTT = s.RTT
+	}
+
+	gradient := 0.5
+	if !s.Dropped {
+		gradient = math.Max(0.5, math.Min(1, tolerance*float64(g.minRTT)/float64(s.RTT)))
+	}
+	// 额外加 sqrt(limit) 的余量去试探更高的并发；利用率不足一半时不试探
+	queue := math.Sqrt(g.limit)
+	if s.InFlight*2 < limit {
+		queue = 0
+	}
+	next := g.limit*gradient + queue
+	g.limit = g.limit*(1-smoothing) + next*smoothing
+
+	minLimit, maxLimit := float64(max(g.MinLimit, 1)), math.Inf(1)
+	if g.MaxLimit > 0 {
+		maxLimit = float64(g.MaxLimit)
+	}
+	g.limit = math.Min(math.Max(g.limit, minLimit), maxLimit)
+	return int(g.limit)
+}
+
+// AdaptiveLimiter 自适应并发限流器
+type AdaptiveLimiter struct {
+	strategy LimitStrategy
+	clock    timesnippet.Clock
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	waiters  *list.List // value: chan struct{}，FIFO
+}
+
+// NewAdaptiveLimiter 初始并发上限为 initial
+func NewAdaptiveLimiter(strategy LimitStrategy, initial int, clock timesnippet.Clock) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		strategy: strategy,
+		clock:    clockOrSystem(clock),
+		limit:    max(initial, 1),
+		waiters:  list.New(),
+	}
+}
+
+// Token 一次获准的调用，必须且只能 Release 一次
+type Token struct {
+	l        *AdaptiveLimiter
+	start    time.Time
+	inFlight int
+	once     sync.Once
+}
+
+// Release 上报调用结果，归还并发额度
+func (t *Token) Release(outcome Outcome) {
+	t.once.Do(func() {
+		t.l.release(t, outcome)
+	})
+}
+
+// Limit 当前并发上限
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight 当前在途请求数
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// TryAcquire 不等待，超过上限直接返回 false
+func (l *AdaptiveLimiter) TryAcquire() (*Token, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= l.limit || l.waiters.Len() > 0 {
+		return nil, false
+	}
+	return l.grantLocked(), true
+}
+
+// Acquire 获取一个并发额度，超过上限时排队直到有额度或 ctx 结束
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (*Token, error) {
+	l.mu.Lock()
+	if l.inFlight < l.limit && l.waiters.Len() == 0 {
+		tok := l.grantLocked()
+		l.mu.Unlock()
+		return tok, nil
+	}
+	ch := make(chan struct{})
+	el := l.waiters.PushBack(ch)
+	l.mu.Unlock()
+
+	select {
+	case <-ch:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.newTokenLocked(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-ch:
+			// 放弃的同时已经被唤醒，额度已经记在我们头上，转交给下一个等待者
+			l.inFlight--
+			l.wakeLocked()
+		default:
+			l.waiters.Remove(el)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (l *AdaptiveLimiter) grantLocked() *Token {
+	l.inFlight++
+	return l.newTokenLocked()
+}
+
+func (l *AdaptiveLimiter) newTokenLocked() *Token {
+	return &Token{l: l, start: l.clock.Now(), inFlight: l.inFlight}
+}
+
+func (l *AdaptiveLimiter) release(t *Token, outcome Outcome) {
+	rtt := l.clock.Now().Sub(t.start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if outcome != OutcomeIgnore {
+		l.limit = max(l.strategy.Update(Sample{RTT: rtt, InFlight: t.inFlight, Dropped: outcome == OutcomeDropped}, l.limit), 1)
+	}
+	l.wakeLocked()
+}
+
+// wakeLocked 按 FIFO 唤醒等待者，直到额度用完；被唤醒者的额度在这里预先占上
+func (l *AdaptiveLimiter) wakeLocked() {
+	for l.inFlight < l.limit && l.waiters.Len() > 0 {
+		ch := l.waiters.Remove(l.waiters.Front()).(chan struct{})
+		l.inFlight++
+		close(ch)
+	}
+}
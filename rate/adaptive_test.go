@@ -0,0 +1,175 @@
+package ratesnippet
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// simBackend 模拟下游：并发不超过 capacity 时延迟为 base，超过后按比例排队变慢
+type simBackend struct {
+	capacity int
+	base     time.Duration
+}
+
+func (b simBackend) latency(inFlight int) time.Duration {
+	if inFlight <= b.capacity {
+		return b.base
+	}
+	return b.base * time.Duration(inFlight) / time.Duration(b.capacity)
+}
+
+type simPhase struct {
+	avgLimit   float64
+	avgLatency time.Duration
+}
+
+// simulate 每一轮把并发打满，按当前并发计算延迟，推进时钟后全部释放。
+// 客户端超时为 timeout：超过的请求按 timeout 计时并上报 OutcomeDropped
+func simulate(l *AdaptiveLimiter, clock *timesnippet.FakeClock, backend simBackend, rounds int, timeout time.Duration) simPhase {
+	var (
+		limits    int
+		latencies time.Duration
+		measured  int
+	)
+	for r := 0; r < rounds; r++ {
+		var tokens []*Token
+		for {
+			tok, ok := l.TryAcquire()
+			if !ok {
+				break
+			}
+			tokens = append(tokens, tok)
+		}
+
+		latency, outcome := backend.latency(len(tokens)), OutcomeSuccess
+		if latency > timeout {
+			latency, outcome = timeout, OutcomeDropped
+		}
+		clock.Advance(latency)
+		for _, tok := range tokens {
+			tok.Release(outcome)
+		}
+
+		// 只统计后一半轮次，跳过收敛过程
+		if r >= rounds/2 {
+			limits += l.Limit()
+			latencies += latency
+			measured++
+		}
+	}
+	return simPhase{
+		avgLimit:   float64(limits) / float64(measured),
+		avgLatency: latencies / time.Duration(measured),
+	}
+}
+
+func TestAdaptiveLimiterSimulation(t *testing.T) {
+	healthy := simBackend{capacity: 40, base: 10 * time.Millisecond}
+	slow := simBackend{capacity: 10, base: 20 * time.Millisecond} // 下游变慢：容量降到 1/4，基础延迟翻倍
+	const timeout = 100 * time.Millisecond
+
+	strategies := []struct {
+		name     string
+		strategy LimitStrategy
+	}{
+		{"AIMD", &AIMD{MaxLimit: 200, Timeout: 25 * time.Millisecond}},
+		{"Vegas", &Vegas{MaxLimit: 200}},
+		{"Gradient", &Gradient{MaxLimit: 200}},
+	}
+
+	for _, s := range strategies {
+		t.Run(s.name, func(t *testing.T) {
+			clock := timesnippet.NewFakeClock(clockStart)
+			l := NewAdaptiveLimiter(s.strategy, 20, clock)
+
+			before := simulate(l, clock, healthy, 400, timeout)
+			after := simulate(l, clock, slow, 400, timeout)
+			t.Logf("健康: 平均上限 %.1f 平均延迟 %v；变慢后: 平均上限 %.1f 平均延迟 %v",
+				before.avgLimit, before.avgLatency, after.avgLimit, after.avgLatency)
+
+			// 健康时能用上下游的容量，但不会无限放大
+			assert.GreaterOrEqual(t, before.avgLimit, float64(healthy.capacity)*0.75)
+			assert.Less(t, before.avgLimit, float64(healthy.capacity)*3)
+
+			// 下游变慢后上限随之收缩，延迟被控制在超时以内
+			assert.Less(t, after.avgLimit, before.avgLimit/2)
+			assert.Less(t, after.avgLatency, timeout)
+		})
+	}
+}
+
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	t.Run("超过上限时排队", func(t *testing.T) {
+		l := NewAdaptiveLimiter(&AIMD{MinLimit: 1, MaxLimit: 1}, 1, timesnippet.NewFakeClock(clockStart))
+		first, err := l.Acquire(context.Background())
+		assert.NoError(t, err)
+
+		acquired := make(chan *Token)
+		go func() {
+			tok, _ := l.Acquire(context.Background())
+			acquired <- tok
+		}()
+
+		select {
+		case <-acquired:
+			t.Fatal("上限为 1 时第二个请求应该排队")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		first.Release(OutcomeSuccess)
+		second := <-acquired
+		assert.NotNil(t, second)
+		assert.Equal(t, 1, l.InFlight())
+		second.Release(OutcomeSuccess)
+		second.Release(OutcomeSuccess) // 重复 Release 无效
+		assert.Equal(t, 0, l.InFlight())
+	})
+
+	t.Run("ctx超时放弃排队", func(t *testing.T) {
+		l := NewAdaptiveLimiter(&AIMD{MaxLimit: 1}, 1, timesnippet.NewFakeClock(clockStart))
+		tok, _ := l.Acquire(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := l.Acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		tok.Release(OutcomeIgnore)
+		assert.Equal(t, 0, l.InFlight())
+		_, ok := l.TryAcquire()
+		assert.True(t, ok)
+	})
+
+	t.Run("并发安全", func(t *testing.T) {
+		l := NewAdaptiveLimiter(&Vegas{MaxLimit: 8}, 4, timesnippet.RealClock)
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			maxSeen int
+		)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tok, err := l.Acquire(context.Background())
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				maxSeen = max(maxSeen, l.InFlight())
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				tok.Release(OutcomeSuccess)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 0, l.InFlight())
+		assert.LessOrEqual(t, maxSeen, 8)
+	})
+}
@@ -0,0 +1,304 @@
+package ratesnippet
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+准入控制：超过并发上限的请求不直接拒绝，而是进入有界等待队列。
+
+	release, err := ac.Admit(ctx, PriorityHigh) // 排队直到拿到额度、ctx 到期、队列满或被丢弃
+	defer release()
+
+- 队列总长度不超过 MaxQueue；队满时高优先级请求挤掉最低优先级中最新入队的那个
+- 有空闲额度时总是先放行高优先级，同一优先级内先进先出
+- 等待时间的上限来自请求自身的 ctx（deadline/取消）
+- CoDel 式丢弃：每次入队和释放额度时检查队列中最早入队请求的排队时间，持续 Interval 都超过 Target，
+  说明队列不是在吸收突发而是长期积压，进入丢弃状态，直到排队时间重新降到 Target 以下
+- 丢弃状态下每次入队或释放丢弃一个排队超过 Target 的请求（ErrShed）：先丢最低优先级，
+  同一优先级内先丢最早入队的；低优先级的超时请求丢完才轮到高优先级
+*/
+
+// Priority 请求优先级，数值越大越优先
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	priorityLevels
+)
+
+var (
+	// ErrQueueFull 等待队列已满，或在队列中被更高优先级的请求挤掉
+	ErrQueueFull = errors.New("ratesnippet: admission queue full")
+	// ErrShed 排队时间过长被丢弃
+	ErrShed = errors.New("ratesnippet: request shed after queueing too long")
+)
+
+// AdmissionConfig 准入控制配置，零值字段使用默认值
+type AdmissionConfig struct {
+	MaxInFlight int           // 同时执行的请求数上限，默认 1
+	MaxQueue    int           // 等待队列长度上限，默认 MaxInFlight 的 10 倍
+	Target      time.Duration // 可接受的排队时间，默认 5ms
+	Interval    time.Duration // 排队时间持续超过 Target 多久开始丢弃，默认 100ms
+	Clock       timesnippet.Clock
+}
+
+// AdmissionStats 准入控制的计数
+type AdmissionStats struct {
+	InFlight int
+	Queued   int
+	Admitted int64 // 放行总数（含直接放行和排队后放行）
+	Rejected int64 // 队满拒绝、被挤出队列的总数
+	Shed     int64 // CoDel 丢弃总数
+}
+
+type waiter struct {
+	ctx      context.Context
+	priority Priority
+	enqueued time.Time
+	el       *list.Element // 出队后置空
+	ready    chan error    // 出队时写入结果，nil 表示获得额度
+}
+
+// AdmissionController 带优先级队列的准入控制器，并发安全
+type AdmissionController struct {
+	cfg AdmissionConfig
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+	queues   [priorityLevels]*list.List
+	stats    AdmissionStats
+
+	firstAbove time.Time // 排队时间首次超过 Target 后 + Interval，零值表示当前低于 Target
+	dropping   bool
+}
+
+// NewAdmissionController 创建准入控制器
+func NewAdmissionController(cfg AdmissionConfig) *AdmissionController {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1
+	}
+	if cfg.MaxQueue <= 0 {
+		cfg.MaxQueue = cfg.MaxInFlight * 10
+	}
+	if cfg.Target <= 0 {
+		cfg.Target = 5 * time.Millisecond
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+	cfg.Clock = clockOrSystem(cfg.Clock)
+	ac := &AdmissionController{cfg: cfg}
+	for i := range ac.queues {
+		ac.queues[i] = list.New()
+	}
+	return ac
+}
+
+// Stats 当前计数
+func (ac *AdmissionController) Stats() AdmissionStats {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	s := ac.stats
+	s.InFlight, s.Queued = ac.inFlight, ac.queued
+	return s
+}
+
+// Admit 申请执行额度。成功时返回的 release 必须在请求结束后调用（重复调用无效）
+func (ac *AdmissionController) Admit(ctx context.Context, p Priority) (release func(), err error) {
+	p = min(max(p, PriorityLow), priorityLevels-1)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ac.cfg.Clock.Now()
+	ac.mu.Lock()
+	ac.shedLocked(now)
+	if ac.inFlight < ac.cfg.MaxInFlight && ac.queued == 0 {
+		ac.inFlight++
+		ac.stats.Admitted++
+		ac.mu.Unlock()
+		return ac.releaseFunc(), nil
+	}
+	if ac.queued >= ac.cfg.MaxQueue && !ac.evictLocked(p) {
+		ac.stats.Rejected++
+		ac.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{ctx: ctx, priority: p, enqueued: now, ready: make(chan error, 1)}
+	w.el = ac.queues[p].PushBack(w)
+	ac.queued++
+	ac.mu.Unlock()
+
+	select {
+	case err := <-w.ready:
+		if err != nil {
+			return nil, err
+		}
+		return ac.releaseFunc(), nil
+	case <-ctx.Done():
+		ac.mu.Lock()
+		if w.el != nil {
+			ac.removeLocked(w)
+			ac.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		ac.mu.Unlock()
+		// 放弃的同时已经出队：拿到额度的话还回去
+		if err := <-w.ready; err == nil {
+			ac.release()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (ac *AdmissionController) releaseFunc() func() {
+	var once sync.Once
+	return func() { once.Do(ac.release) }
+}
+
+func (ac *AdmissionController) release() {
+	now := ac.cfg.Clock.Now()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.inFlight--
+	ac.shedLocked(now)
+	ac.dispatchLocked()
+}
+
+// evictLocked 挤掉优先级低于 p 的最新入队的请求，没有可挤的返回 false
+func (ac *AdmissionController) evictLocked(p Priority) bool {
+	for lp := PriorityLow; lp < p; lp++ {
+		if back := ac.queues[lp].Back(); back != nil {
+			w := back.Value.(*waiter)
+			ac.removeLocked(w)
+			ac.stats.Rejected++
+			w.ready <- ErrQueueFull
+			return true
+		}
+	}
+	return false
+}
+
+func (ac *AdmissionController) removeLocked(w *waiter) {
+	ac.queues[w.priority].Remove(w.el)
+	w.el = nil
+	ac.queued--
+}
+
+// dispatchLocked 按优先级出队，直到额度用完或队列为空
+func (ac *AdmissionController) dispatchLocked() {
+	for ac.inFlight < ac.cfg.MaxInFlight {
+		w := ac.frontLocked()
+		if w == nil {
+			return
+		}
+		ac.removeLocked(w)
+		if err := w.ctx.Err(); err != nil {
+			w.ready <- err
+			continue
+		}
+		ac.inFlight++
+		ac.stats.Admitted++
+		w.ready <- nil
+	}
+}
+
+func (ac *AdmissionController) frontLocked() *waiter {
+	for p := priorityLevels - 1; p >= PriorityLow; p-- {
+		if front := ac.queues[p].Front(); front != nil {
+			return front.Value.(*waiter)
+		}
+	}
+	return nil
+}
+
+// oldestLocked 所有优先级中最早入队的请求，即各队列队首中最早的那个
+func (ac *AdmissionController) oldestLocked() *waiter {
+	var oldest *waiter
+	for _, q := range ac.queues {
+		if front := q.Front(); front != nil {
+			if w := front.Value.(*waiter); oldest == nil || w.enqueued.Before(oldest.enqueued) {
+				oldest = w
+			}
+		}
+	}
+	return oldest
+}
+
+// shedLocked 按最早入队请求的排队时间更新 CoDel 状态，处于丢弃状态时丢弃一个请求：
+// 从最低优先级开始找排队超过 Target 的队首
+func (ac *AdmissionController) shedLocked(now time.Time) {
+	oldest := ac.oldestLocked()
+	if oldest == nil {
+		// 队列清空，退出丢弃状态
+		ac.firstAbove, ac.dropping = time.Time{}, false
+		return
+	}
+	if !ac.shouldShedLocked(now.Sub(oldest.enqueued), now) {
+		return
+	}
+	for _, q := range ac.queues {
+		front := q.Front()
+		if front == nil {
+			continue
+		}
+		// 队列内先进先出，队首没超时的话后面的也没有
+		if w := front.Value.(*waiter); now.Sub(w.enqueued) >= ac.cfg.Target {
+			ac.removeLocked(w)
+			ac.stats.Shed++
+			w.ready <- ErrShed
+			return
+		}
+	}
+}
+
+// shouldShedLocked CoDel 判断：排队时间持续 Interval 高于 Target 后进入丢弃状态
+func (ac *AdmissionController) shouldShedLocked(sojourn time.Duration, now time.Time) bool {
+	if sojourn < ac.cfg.Target {
+		ac.firstAbove, ac.dropping = time.Time{}, false
+		return false
+	}
+	if ac.firstAbove.IsZero() {
+		ac.firstAbove = now.Add(ac.cfg.Interval)
+		return false
+	}
+	if !now.Before(ac.firstAbove) {
+		ac.dropping = true
+	}
+	return ac.dropping
+}
+
+// PriorityFunc 从请求中取优先级
+type PriorityFunc func(r *http.Request) Priority
+
+// AdmissionMiddleware 经过准入控制后再调用 next，队满或被丢弃时返回 503
+func AdmissionMiddleware(ac *AdmissionController, priority PriorityFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := PriorityNormal
+		if priority != nil {
+			p = priority(r)
+		}
+		release, err := ac.Admit(r.Context(), p)
+		if err != nil {
+			if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrShed) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+			// ctx 结束说明客户端已经放弃，不必再写响应
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
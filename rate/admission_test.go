@@ -0,0 +1,233 @@
+package ratesnippet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+type admitResult struct {
+	name    string
+	release func()
+	err     error
+}
+
+// admitAsync 在后台 Admit，等它进入队列后再返回。入队时可能同时丢弃一个请求，所以看 Queued+Shed
+func admitAsync(t *testing.T, ac *AdmissionController, ctx context.Context, name string, p Priority, results chan<- admitResult) {
+	before := ac.Stats()
+	go func() {
+		release, err := ac.Admit(ctx, p)
+		results <- admitResult{name: name, release: release, err: err}
+	}()
+	assert.Eventually(t, func() bool {
+		s := ac.Stats()
+		return s.Queued+int(s.Shed) > before.Queued+int(before.Shed)
+	}, time.Second, time.Millisecond)
+}
+
+func TestAdmissionController(t *testing.T) {
+	t.Run("高优先级先放行", func(t *testing.T) {
+		ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 1, Target: time.Hour, Clock: timesnippet.NewFakeClock(clockStart)})
+		hold, err := ac.Admit(context.Background(), PriorityNormal)
+		assert.NoError(t, err)
+
+		results := make(chan admitResult, 4)
+		admitAsync(t, ac, context.Background(), "low", PriorityLow, results)
+		admitAsync(t, ac, context.Background(), "normal-1", PriorityNormal, results)
+		admitAsync(t, ac, context.Background(), "high", PriorityHigh, results)
+		admitAsync(t, ac, context.Background(), "normal-2", PriorityNormal, results)
+
+		var order []string
+		hold()
+		for i := 0; i < 4; i++ {
+			r := <-results
+			assert.NoError(t, r.err)
+			order = append(order, r.name)
+			r.release()
+		}
+		assert.Equal(t, []string{"high", "normal-1", "normal-2", "low"}, order)
+		assert.Equal(t, AdmissionStats{Admitted: 5}, ac.Stats())
+	})
+
+	t.Run("队满时挤掉低优先级", func(t *testing.T) {
+		ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 1, MaxQueue: 2, Clock: timesnippet.NewFakeClock(clockStart)})
+		hold, _ := ac.Admit(context.Background(), PriorityNormal)
+		defer hold()
+
+		results := make(chan admitResult, 4)
+		admitAsync(t, ac, context.Background(), "low-1", PriorityLow, results)
+		admitAsync(t, ac, context.Background(), "low-2", PriorityLow, results)
+
+		// 同优先级不能挤
+		_, err := ac.Admit(context.Background(), PriorityLow)
+		assert.ErrorIs(t, err, ErrQueueFull)
+
+		go func() {
+			release, err := ac.Admit(context.Background(), PriorityHigh)
+			results <- admitResult{name: "high", release: release, err: err}
+		}()
+		r := <-results
+		assert.Equal(t, "low-2", r.name, "挤掉最新入队的低优先级请求")
+		assert.ErrorIs(t, r.err, ErrQueueFull)
+		assert.Equal(t, int64(2), ac.Stats().Rejected)
+	})
+
+	t.Run("ctx到期放弃排队", func(t *testing.T) {
+		ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 1, Clock: timesnippet.NewFakeClock(clockStart)})
+		hold, _ := ac.Admit(context.Background(), PriorityNormal)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := ac.Admit(ctx, PriorityHigh)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 0, ac.Stats().Queued)
+
+		hold()
+		release, err := ac.Admit(context.Background(), PriorityLow)
+		assert.NoError(t, err)
+		release()
+		assert.Equal(t, 0, ac.Stats().InFlight)
+	})
+
+	t.Run("排队持续超时后丢弃最早的请求", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		ac := NewAdmissionController(AdmissionConfig{
+			MaxInFlight: 1, Target: 10 * time.Millisecond, Interval: 100 * time.Millisecond, Clock: clock,
+		})
+		hold, _ := ac.Admit(context.Background(), PriorityNormal)
+
+		// 每个请求单独一个通道，避免同一次出队里多个结果的到达顺序不确定
+		results := map[string]chan admitResult{}
+		enqueue := func(name string) {
+			results[name] = make(chan admitResult, 1)
+			admitAsync(t, ac, context.Background(), name, PriorityNormal, results[name])
+		}
+		enqueue("a")
+		clock.Advance(time.Millisecond)
+		enqueue("b")
+
+		// 第一次超过 Target：只开始计时，照常放行
+		clock.Advance(50 * time.Millisecond)
+		hold()
+		a := <-results["a"]
+		assert.NoError(t, a.err)
+		enqueue("c")
+
+		// 超过 Target 已持续 Interval：d 入队时丢弃最早的 b，a 释放时丢弃 c；
+		// d 的排队时间低于 Target，照常放行并退出丢弃状态
+		clock.Advance(150 * time.Millisecond)
+		enqueue("d")
+		assert.ErrorIs(t, (<-results["b"]).err, ErrShed)
+		clock.Advance(5 * time.Millisecond)
+		a.release()
+		assert.ErrorIs(t, (<-results["c"]).err, ErrShed)
+		d := <-results["d"]
+		assert.NoError(t, d.err)
+		d.release()
+		assert.Equal(t, int64(2), ac.Stats().Shed)
+
+		// 队列清空后恢复正常
+		release, err := ac.Admit(context.Background(), PriorityLow)
+		assert.NoError(t, err)
+		release()
+	})
+
+	t.Run("混合优先级过载时先丢低优先级", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		ac := NewAdmissionController(AdmissionConfig{
+			MaxInFlight: 1, MaxQueue: 100, Target: 10 * time.Millisecond, Interval: 100 * time.Millisecond, Clock: clock,
+		})
+		hold, _ := ac.Admit(context.Background(), PriorityNormal)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		results := map[string]chan admitResult{}
+		enqueue := func(name string, p Priority) {
+			results[name] = make(chan admitResult, 1)
+			admitAsync(t, ac, ctx, name, p, results[name])
+		}
+		enqueue("high-1", PriorityHigh)
+		enqueue("low-1", PriorityLow)
+		enqueue("normal-1", PriorityNormal)
+		enqueue("low-2", PriorityLow)
+
+		// high-1 排队最久，但只看高优先级队首的话它会被最先丢掉
+		clock.Advance(50 * time.Millisecond)
+		enqueue("high-2", PriorityHigh) // 开始计时
+		clock.Advance(100 * time.Millisecond)
+		for i, want := range []string{"low-1", "low-2", "normal-1"} {
+			enqueue(fmt.Sprintf("high-%d", i+3), PriorityHigh)
+			assert.ErrorIs(t, (<-results[want]).err, ErrShed, want)
+		}
+		s := ac.Stats()
+		assert.Equal(t, int64(3), s.Shed)
+		assert.Equal(t, 5, s.Queued, "高优先级请求都还在排队")
+
+		// 低优先级丢完才轮到排队超时的高优先级，最早入队的先丢
+		hold()
+		assert.ErrorIs(t, (<-results["high-1"]).err, ErrShed)
+		r := <-results["high-2"]
+		assert.NoError(t, r.err)
+		r.release()
+	})
+
+	t.Run("并发安全", func(t *testing.T) {
+		ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 4, MaxQueue: 20, Target: time.Second})
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			maxSeen int
+		)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				release, err := ac.Admit(context.Background(), Priority(i%3))
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				maxSeen = max(maxSeen, ac.Stats().InFlight)
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				release()
+			}(i)
+		}
+		wg.Wait()
+		s := ac.Stats()
+		assert.Equal(t, 0, s.InFlight)
+		assert.Equal(t, 0, s.Queued)
+		assert.Equal(t, int64(100), s.Admitted+s.Rejected+s.Shed)
+		assert.LessOrEqual(t, maxSeen, 4)
+	})
+}
+
+func TestAdmissionMiddleware(t *testing.T) {
+	ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 1, MaxQueue: 1, Clock: timesnippet.NewFakeClock(clockStart)})
+	hold, _ := ac.Admit(context.Background(), PriorityNormal)
+
+	results := make(chan admitResult, 1)
+	admitAsync(t, ac, context.Background(), "queued", PriorityNormal, results)
+
+	h := AdmissionMiddleware(ac, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	hold()
+	(<-results).release()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
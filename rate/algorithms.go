@@ -0,0 +1,306 @@
+package ratesnippet
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+令牌桶之外的几种限流算法，统一实现 Limiter 接口，时间全部来自注入的 Clock。
+
+| 算法                  | 状态                 | 特点                                                         |
+|-----------------------|----------------------|--------------------------------------------------------------|
+| TokenBucket           | 令牌数 + 上次时间    | 允许突发 burst，长期速率 r（x/time/rate）                    |
+| FixedWindow           | 当前窗口计数         | 最简单；窗口边界前后各打满，短时间内可放过 2 倍流量          |
+| SlidingWindowCounter  | 上一窗口 + 当前窗口  | 用上一窗口计数按时间比例加权估算，O(1) 内存，近似滑动窗口    |
+| SlidingWindowLog      | 窗口内每个请求时间戳 | 精确滑动窗口，任意 Window 长度内不超过 Limit，内存 O(Limit)  |
+| GCRA                  | 理论到达时间 TAT     | 与令牌桶等价，只存一个时间戳，Redis 限流常用                 |
+| LeakyBucket           | 队尾的预定时间       | 不拒绝而是排队：按固定间隔放行，返回需要等待的 Delay；队满才拒绝 |
+*/
+
+// Limiter 不区分 key 的单个限流器
+type Limiter interface {
+	Take() Decision
+}
+
+func clockOrSystem(c timesnippet.Clock) timesnippet.Clock {
+	if c == nil {
+		return timesnippet.RealClock
+	}
+	return c
+}
+
+// TokenBucket 包装 rate.Limiter
+type TokenBucket struct {
+	lim   *rate.Limiter
+	clock timesnippet.Clock
+}
+
+// NewTokenBucket 速率 r、突发 burst 的令牌桶
+func NewTokenBucket(r rate.Limit, burst int, clock timesnippet.Clock) *TokenBucket {
+	return &TokenBucket{lim: rate.NewLimiter(r, burst), clock: clockOrSystem(clock)}
+}
+
+func (b *TokenBucket) Take() Decision {
+	return takeToken(b.lim, b.clock.Now())
+}
+
+// takeToken 从令牌桶取一个令牌并换算成 Decision
+func takeToken(lim *rate.Limiter, now time.Time) Decision {
+	allowed := lim.AllowN(now, 1)
+
+	r, burst := float64(lim.Limit()), lim.Burst()
+	tokens := math.Max(lim.TokensAt(now), 0)
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     burst,
+		Remaining: int(tokens),
+	}
+	if r > 0 && !math.IsInf(r, 1) {
+		d.Reset = secondsToDuration((float64(burst) - tokens) / r)
+		if !allowed {
+			d.RetryAfter = secondsToDuration((1 - tokens) / r)
+		}
+	}
+	return d
+}
+
+// FixedWindow 固定窗口计数
+type FixedWindow struct {
+	limit  int
+	window time.Duration
+	clock  timesnippet.Clock
+
+	mu    sync.Mutex
+	start time.Time
+	count int
+}
+
+// NewFixedWindow 每个 window 内最多 limit 个请求，窗口按 Unix 零点对齐
+func NewFixedWindow(limit int, window time.Duration, clock timesnippet.Clock) *FixedWindow {
+	return &FixedWindow{limit: limit, window: window, clock: clockOrSystem(clock)}
+}
+
+func (f *FixedWindow) Take() Decision {
+	now := f.clock.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if start := now.Truncate(f.window); !start.Equal(f.start) {
+		f.start, f.count = start, 0
+	}
+	reset := f.start.Add(f.window).Sub(now)
+	d := Decision{Limit: f.limit, Reset: reset}
+	if f.count < f.limit {
+		f.count++
+		d.Allowed = true
+	} else {
+		d.RetryAfter = reset
+	}
+	d.Remaining = f.limit - f.count
+	return d
+}
+
+// SlidingWindowCounter 滑动窗口计数：估算值 = 上一窗口计数 × 上一窗口在滑动窗口内的占比 + 当前窗口计数
+type SlidingWindowCounter struct {
+	limit  int
+	window time.Duration
+	clock  timesnippet.Clock
+
+	mu        sync.Mutex
+	start     time.Time
+	prevCount int
+	currCount int
+}
+
+// NewSlidingWindowCounter 任意 window 长度内约 limit 个请求
+func NewSlidingWindowCounter(limit int, window time.Duration, clock timesnippet.Clock) *SlidingWindowCounter {
+	return &SlidingWindowCounter{limit: limit, window: window, clock: clockOrSystem(clock)}
+}
+
+func (s *SlidingWindowCounter) Take() Decision {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := now.Truncate(s.window)
+	switch {
+	case start.Equal(s.start):
+	case start.Sub(s.start) == s.window:
+		s.start, s.prevCount, s.currCount = start, s.currCount, 0
+	default:
+		// 跳过了至少一个完整窗口
+		s.start, s.prevCount, s.currCount = start, 0, 0
+	}
+
+	// 估算值 = prev×(window-elapsed)/window + curr，两边同乘 window 用整数比较，避免浮点误差
+	elapsed := now.Sub(s.start)
+	w, rest := int64(s.window), int64(s.window-elapsed)
+	scaled := int64(s.prevCount)*rest + int64(s.currCount)*w
+
+	d := Decision{Limit: s.limit, Reset: s.window - elapsed + s.window}
+	if scaled+w <= int64(s.limit)*w {
+		s.currCount++
+		scaled += w
+		d.Allowed = true
+	} else {
+		d.RetryAfter = s.retryAfter(elapsed)
+	}
+	d.Remaining = max(int((int64(s.limit)*w-scaled)/w), 0)
+	return d
+}
+
+// retryAfter 上一窗口的权重随时间线性下降，求估算值降到 limit-1 的时刻；当前窗口内等不到就等到下个窗口
+func (s *SlidingWindowCounter) retryAfter(elapsed time.Duration) time.Duration {
+	rest := s.window - elapsed
+	// 需要 prev×(window-t)/window <= limit-1-curr，即 t >= window×(prev-(limit-1-curr))/prev
+	room := s.limit - 1 - s.currCount
+	if s.prevCount == 0 || room < 0 {
+		return rest
+	}
+	num := int64(s.window) * int64(s.prevCount-room)
+	at := time.Duration((num + int64(s.prevCount) - 1) / int64(s.prevCount)) // 向上取整
+	if at <= elapsed || at >= s.window {
+		return rest
+	}
+	return at - elapsed
+}
+
+// SlidingWindowLog 滑动窗口日志：记录窗口内每个请求的时间
+type SlidingWindowLog struct {
+	limit  int
+	window time.Duration
+	clock  timesnippet.Clock
+
+	mu  sync.Mutex
+	log []time.Time // 按时间升序，长度不超过 limit
+}
+
+// NewSlidingWindowLog 任意 window 长度内严格不超过 limit 个请求
+func NewSlidingWindowLog(limit int, window time.Duration, clock timesnippet.Clock) *SlidingWindowLog {
+	return &SlidingWindowLog{limit: limit, window: window, clock: clockOrSystem(clock)}
+}
+
+func (s *SlidingWindowLog) Take() Decision {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-s.window)
+	i := 0
+	for i < len(s.log) && !s.log[i].After(cutoff) {
+		i++
+	}
+	s.log = s.log[i:]
+
+	d := Decision{Limit: s.limit}
+	if len(s.log) < s.limit {
+		s.log = append(s.log, now)
+		d.Allowed = true
+	} else {
+		d.RetryAfter = s.log[0].Add(s.window).Sub(now)
+	}
+	d.Remaining = s.limit - len(s.log)
+	if n := len(s.log); n > 0 {
+		d.Reset = s.log[n-1].Add(s.window).Sub(now)
+	}
+	return d
+}
+
+// GCRA 通用信元速率算法：每个请求把理论到达时间 TAT 推后一个发射间隔，TAT 领先当前时间超过容差就拒绝
+type GCRA struct {
+	interval  time.Duration // 发射间隔 = 1/rate
+	tolerance time.Duration // 容差 = interval × burst
+	burst     int
+	clock     timesnippet.Clock
+
+	mu  sync.Mutex
+	tat time.Time
+}
+
+// NewGCRA 速率 r（每秒）、突发 burst，行为与同参数的令牌桶一致
+func NewGCRA(r float64, burst int, clock timesnippet.Clock) *GCRA {
+	interval := time.Duration(float64(time.Second) / r)
+	return &GCRA{
+		interval:  interval,
+		tolerance: interval * time.Duration(burst),
+		burst:     burst,
+		clock:     clockOrSystem(clock),
+	}
+}
+
+func (g *GCRA) Take() Decision {
+	now := g.clock.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(g.interval)
+	allowAt := newTat.Add(-g.tolerance)
+
+	d := Decision{Limit: g.burst}
+	if now.Before(allowAt) {
+		d.RetryAfter = allowAt.Sub(now)
+		d.Reset = tat.Sub(now)
+		d.Remaining = 0
+		return d
+	}
+	g.tat = newTat
+	d.Allowed = true
+	d.Reset = newTat.Sub(now)
+	d.Remaining = int(now.Sub(allowAt) / g.interval)
+	return d
+}
+
+// LeakyBucket 漏桶（排队）：请求按固定间隔流出，Allowed 时 Delay 为需要排队等待的时间，队列满才拒绝
+type LeakyBucket struct {
+	interval time.Duration
+	capacity int
+	clock    timesnippet.Clock
+
+	mu   sync.Mutex
+	last time.Time // 最后一个已排队请求的放行时刻
+}
+
+// NewLeakyBucket 每秒流出 r 个请求，最多排队 capacity 个
+func NewLeakyBucket(r float64, capacity int, clock timesnippet.Clock) *LeakyBucket {
+	return &LeakyBucket{
+		interval: time.Duration(float64(time.Second) / r),
+		capacity: capacity,
+		clock:    clockOrSystem(clock),
+	}
+}
+
+func (l *LeakyBucket) Take() Decision {
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := now
+	if !l.last.IsZero() && l.last.Add(l.interval).After(now) {
+		next = l.last.Add(l.interval)
+	}
+	delay := next.Sub(now)
+	queued := int(delay / l.interval) // 排在前面的请求数
+
+	d := Decision{Limit: l.capacity}
+	if queued >= l.capacity {
+		d.RetryAfter = delay - time.Duration(l.capacity-1)*l.interval
+		d.Reset = delay
+		return d
+	}
+	l.last = next
+	d.Allowed = true
+	d.Delay = delay
+	d.Remaining = l.capacity - queued - 1
+	d.Reset = delay + l.interval
+	return d
+}
@@ -0,0 +1,180 @@
+package ratesnippet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// burstTrace 同一条请求轨迹：
+//
+//	A: t=0 瞬间来 15 个请求（突发）
+//	B: t=(0,2s] 每 50ms 一个请求，即 20 QPS，是限额的两倍
+//	C: 空闲到 t=3.9s，在 3.9s 和 4.0s 各来 10 个（正好跨过固定窗口的边界）
+type traceEvent struct {
+	at    time.Duration
+	phase string
+}
+
+func burstTrace() []traceEvent {
+	var trace []traceEvent
+	for i := 0; i < 15; i++ {
+		trace = append(trace, traceEvent{0, "A"})
+	}
+	for at := 50 * time.Millisecond; at <= 2*time.Second; at += 50 * time.Millisecond {
+		trace = append(trace, traceEvent{at, "B"})
+	}
+	for i := 0; i < 10; i++ {
+		trace = append(trace, traceEvent{3900 * time.Millisecond, "C"})
+	}
+	for i := 0; i < 10; i++ {
+		trace = append(trace, traceEvent{4 * time.Second, "C"})
+	}
+	return trace
+}
+
+type traceResult struct {
+	allowed   map[string]int
+	decisions []Decision
+}
+
+func runTrace(newLimiter func(timesnippet.Clock) Limiter) traceResult {
+	clock := timesnippet.NewFakeClock(clockStart)
+	start := clock.Now()
+	l := newLimiter(clock)
+
+	res := traceResult{allowed: map[string]int{}}
+	for _, ev := range burstTrace() {
+		clock.Advance(start.Add(ev.at).Sub(clock.Now()))
+		d := l.Take()
+		res.decisions = append(res.decisions, d)
+		if d.Allowed {
+			res.allowed[ev.phase]++
+		}
+	}
+	return res
+}
+
+// 所有算法都配置成“每秒 10 个”
+func TestLimiterComparison(t *testing.T) {
+	limiters := []struct {
+		name string
+		new  func(timesnippet.Clock) Limiter
+	}{
+		{"TokenBucket", func(c timesnippet.Clock) Limiter { return NewTokenBucket(10, 10, c) }},
+		{"GCRA", func(c timesnippet.Clock) Limiter { return NewGCRA(10, 10, c) }},
+		{"FixedWindow", func(c timesnippet.Clock) Limiter { return NewFixedWindow(10, time.Second, c) }},
+		{"SlidingWindowCounter", func(c timesnippet.Clock) Limiter { return NewSlidingWindowCounter(10, time.Second, c) }},
+		{"SlidingWindowLog", func(c timesnippet.Clock) Limiter { return NewSlidingWindowLog(10, time.Second, c) }},
+		{"LeakyBucket", func(c timesnippet.Clock) Limiter { return NewLeakyBucket(10, 10, c) }},
+	}
+
+	results := map[string]traceResult{}
+	t.Logf("%-22s %4s %4s %4s", "算法", "A/15", "B/40", "C/20")
+	for _, l := range limiters {
+		r := runTrace(l.new)
+		results[l.name] = r
+		t.Logf("%-22s %4d %4d %4d", l.name, r.allowed["A"], r.allowed["B"], r.allowed["C"])
+	}
+
+	t.Run("GCRA与令牌桶等价", func(t *testing.T) {
+		assert.Equal(t, results["TokenBucket"].allowed, results["GCRA"].allowed)
+		assert.Equal(t, 10, results["GCRA"].allowed["A"], "突发 burst=10")
+	})
+
+	t.Run("固定窗口在边界处放过两倍流量", func(t *testing.T) {
+		assert.Equal(t, 20, results["FixedWindow"].allowed["C"])
+	})
+
+	t.Run("滑动窗口日志严格限制任意1秒内的请求数", func(t *testing.T) {
+		assert.Equal(t, 10, results["SlidingWindowLog"].allowed["C"])
+
+		var times []time.Duration
+		for i, ev := range burstTrace() {
+			if results["SlidingWindowLog"].decisions[i].Allowed {
+				times = append(times, ev.at)
+			}
+		}
+		for i := range times {
+			n := 0
+			for j := i; j < len(times) && times[j]-times[i] < time.Second; j++ {
+				n++
+			}
+			assert.LessOrEqual(t, n, 10, fmt.Sprintf("从 %v 开始的 1 秒", times[i]))
+		}
+	})
+
+	t.Run("滑动窗口计数近似日志", func(t *testing.T) {
+		c := results["SlidingWindowCounter"].allowed["C"]
+		assert.Less(t, c, results["FixedWindow"].allowed["C"])
+		assert.InDelta(t, results["SlidingWindowLog"].allowed["C"], c, 1)
+	})
+
+	t.Run("漏桶排队而不是拒绝", func(t *testing.T) {
+		r := results["LeakyBucket"]
+		assert.Equal(t, 10, r.allowed["A"], "队列容量 10，多出的 5 个被拒绝")
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, time.Duration(i)*100*time.Millisecond, r.decisions[i].Delay)
+		}
+		assert.False(t, r.decisions[10].Allowed)
+		assert.Equal(t, 100*time.Millisecond, r.decisions[10].RetryAfter)
+	})
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	t.Run("FixedWindow", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l := NewFixedWindow(1, time.Second, clock)
+		clock.Advance(300 * time.Millisecond)
+		assert.True(t, l.Take().Allowed)
+		d := l.Take()
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 700*time.Millisecond, d.RetryAfter)
+	})
+
+	t.Run("SlidingWindowLog", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l := NewSlidingWindowLog(2, time.Second, clock)
+		l.Take()
+		clock.Advance(400 * time.Millisecond)
+		l.Take()
+		d := l.Take()
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 600*time.Millisecond, d.RetryAfter)
+
+		clock.Advance(d.RetryAfter)
+		assert.True(t, l.Take().Allowed)
+	})
+
+	t.Run("GCRA", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l := NewGCRA(10, 2, clock)
+		assert.Equal(t, 1, l.Take().Remaining)
+		assert.Equal(t, 0, l.Take().Remaining)
+		d := l.Take()
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 100*time.Millisecond, d.RetryAfter)
+
+		clock.Advance(d.RetryAfter)
+		assert.True(t, l.Take().Allowed)
+	})
+
+	t.Run("SlidingWindowCounter", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l := NewSlidingWindowCounter(10, time.Second, clock)
+		for i := 0; i < 10; i++ {
+			l.Take()
+		}
+		clock.Advance(time.Second) // 上一窗口 10 个，权重 1
+		d := l.Take()
+		assert.False(t, d.Allowed)
+		// 权重降到 0.9 时估算值为 9，可以再放一个
+		assert.Equal(t, 100*time.Millisecond, d.RetryAfter)
+		clock.Advance(d.RetryAfter)
+		assert.True(t, l.Take().Allowed)
+	})
+}
@@ -0,0 +1,189 @@
+package ratesnippet
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+KeyedLimiter 按 key（用户 ID、IP、租户）各自限流，每个 key 懒创建一个 rate.Limiter。
+
+- 空闲超过 TTL 的 key 被淘汰；key 数超过 MaxKeys 时淘汰最久未访问的（LRU）
+- SetOverride 可以单独调整某个 key 的速率和突发，已存在的 limiter 通过 SetLimitAt/SetBurstAt 原地修改，桶里的令牌不丢
+- 并发安全：map/链表由一把锁保护，rate.Limiter 本身并发安全，取令牌不占用这把锁
+
+令牌桶回顾（rate.NewLimiter(r, b)）：
+桶容量 b，每秒放入 r 个令牌，Allow 取一个令牌，没有就返回 false。
+所以长期速率是 r，但空闲一段时间后桶满了，可以瞬间放过 b 个请求（突发）。
+*/
+
+// KeyedLimiterConfig KeyedLimiter 的配置
+type KeyedLimiterConfig struct {
+	Rate    rate.Limit        // 每个 key 的默认速率
+	Burst   int               // 每个 key 的默认突发
+	TTL     time.Duration     // key 空闲多久后淘汰，0 表示不按时间淘汰
+	MaxKeys int               // 最多保留多少个 key，0 表示不限
+	Clock   timesnippet.Clock // 为空时使用 timesnippet.RealClock
+}
+
+type limit struct {
+	rate  rate.Limit
+	burst int
+}
+
+type keyedEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// KeyedLimiter 按 key 限流
+type KeyedLimiter struct {
+	cfg KeyedLimiterConfig
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element // value: *keyedEntry
+	lru       *list.List               // 表头最近访问，表尾最久未访问
+	overrides map[string]limit
+}
+
+// NewKeyedLimiter 创建按 key 限流器
+func NewKeyedLimiter(cfg KeyedLimiterConfig) *KeyedLimiter {
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	return &KeyedLimiter{
+		cfg:       cfg,
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+		overrides: make(map[string]limit),
+	}
+}
+
+// Allow 等价于 AllowN(key, 1)
+func (k *KeyedLimiter) Allow(key string) bool {
+	return k.AllowN(key, 1)
+}
+
+// AllowN 判断 key 此刻能否取走 n 个令牌
+func (k *KeyedLimiter) AllowN(key string, n int) bool {
+	now := k.cfg.Clock.Now()
+	return k.get(key, now).AllowN(now, n)
+}
+
+// Reserve 为 key 预留一个令牌，调用方按 Reservation.DelayFrom 等待或 Cancel
+func (k *KeyedLimiter) Reserve(key string) *rate.Reservation {
+	now := k.cfg.Clock.Now()
+	return k.get(key, now).ReserveN(now, 1)
+}
+
+// Wait 阻塞直到 key 取到一个令牌或 ctx 结束。
+// 不用 rate.Limiter.Wait：它按真实时间计时，和 AllowN/Reserve 用的 cfg.Clock 混在一起会打乱令牌数
+func (k *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := k.cfg.Clock.Now()
+	lim := k.get(key, now)
+	r := lim.ReserveN(now, 1)
+	if !r.OK() {
+		return fmt.Errorf("ratesnippet: Wait(key=%q) exceeds limiter's burst %d", key, lim.Burst())
+	}
+	delay := r.DelayFrom(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := k.cfg.Clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		// 归还预留的令牌，后面的请求不用替放弃的调用方排队
+		r.CancelAt(k.cfg.Clock.Now())
+		return ctx.Err()
+	}
+}
+
+// Limiter 返回 key 对应的 rate.Limiter，不存在则创建
+func (k *KeyedLimiter) Limiter(key string) *rate.Limiter {
+	return k.get(key, k.cfg.Clock.Now())
+}
+
+// SetOverride 单独设置 key 的速率和突发
+func (k *KeyedLimiter) SetOverride(key string, r rate.Limit, burst int) {
+	now := k.cfg.Clock.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.overrides[key] = limit{rate: r, burst: burst}
+	if el, ok := k.entries[key]; ok {
+		lim := el.Value.(*keyedEntry).limiter
+		lim.SetLimitAt(now, r)
+		lim.SetBurstAt(now, burst)
+	}
+}
+
+// RemoveOverride 恢复 key 的默认速率和突发
+func (k *KeyedLimiter) RemoveOverride(key string) {
+	now := k.cfg.Clock.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.overrides, key)
+	if el, ok := k.entries[key]; ok {
+		lim := el.Value.(*keyedEntry).limiter
+		lim.SetLimitAt(now, k.cfg.Rate)
+		lim.SetBurstAt(now, k.cfg.Burst)
+	}
+}
+
+// Len 当前保留的 key 数量（会先淘汰过期 key）
+func (k *KeyedLimiter) Len() int {
+	now := k.cfg.Clock.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.evictLocked(now)
+	return len(k.entries)
+}
+
+func (k *KeyedLimiter) get(key string, now time.Time) *rate.Limiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if el, ok := k.entries[key]; ok {
+		e := el.Value.(*keyedEntry)
+		e.lastSeen = now
+		k.lru.MoveToFront(el)
+		k.evictLocked(now)
+		return e.limiter
+	}
+
+	l := limit{rate: k.cfg.Rate, burst: k.cfg.Burst}
+	if o, ok := k.overrides[key]; ok {
+		l = o
+	}
+	e := &keyedEntry{key: key, limiter: rate.NewLimiter(l.rate, l.burst), lastSeen: now}
+	k.entries[key] = k.lru.PushFront(e)
+	k.evictLocked(now)
+	return e.limiter
+}
+
+// evictLocked 链表按访问时间有序，从表尾淘汰即可，均摊 O(1)
+func (k *KeyedLimiter) evictLocked(now time.Time) {
+	for el := k.lru.Back(); el != nil; el = k.lru.Back() {
+		e := el.Value.(*keyedEntry)
+		expired := k.cfg.TTL > 0 && now.Sub(e.lastSeen) >= k.cfg.TTL
+		overflow := k.cfg.MaxKeys > 0 && k.lru.Len() > k.cfg.MaxKeys
+		if !expired && !overflow {
+			return
+		}
+		k.lru.Remove(el)
+		delete(k.entries, e.key)
+	}
+}
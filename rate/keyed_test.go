@@ -0,0 +1,140 @@
+package ratesnippet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// clockStart 测试里 FakeClock 的起始时刻
+var clockStart = time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+
+func TestKeyedLimiter(t *testing.T) {
+	t.Run("每个key独立计数", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 2, Clock: clock})
+
+		assert.True(t, kl.Allow("alice"))
+		assert.True(t, kl.Allow("alice"))
+		assert.False(t, kl.Allow("alice"))
+		// alice 用完不影响 bob
+		assert.True(t, kl.Allow("bob"))
+
+		clock.Advance(time.Second)
+		assert.True(t, kl.Allow("alice"))
+		assert.False(t, kl.Allow("alice"))
+	})
+
+	t.Run("TTL淘汰空闲key", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 1, TTL: time.Minute, Clock: clock})
+
+		kl.Allow("a")
+		clock.Advance(30 * time.Second)
+		kl.Allow("b")
+		assert.Equal(t, 2, kl.Len())
+
+		clock.Advance(30 * time.Second)
+		assert.Equal(t, 1, kl.Len(), "a 空闲满一分钟被淘汰")
+		clock.Advance(30 * time.Second)
+		assert.Equal(t, 0, kl.Len())
+	})
+
+	t.Run("LRU淘汰最久未访问的key", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 1, MaxKeys: 2, Clock: clock})
+
+		kl.Allow("a")
+		kl.Allow("b")
+		kl.Allow("a") // a 变为最近访问
+		kl.Allow("c") // 淘汰 b
+		assert.Equal(t, 2, kl.Len())
+
+		// a 仍保留令牌状态（已用完），b 被淘汰后重建，桶是满的
+		assert.False(t, kl.Allow("a"))
+		assert.True(t, kl.Allow("b"))
+	})
+
+	t.Run("单独覆盖速率且保留令牌", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 1, Clock: clock})
+
+		// 还没创建的 key：覆盖在创建时生效
+		kl.SetOverride("vip", 10, 5)
+		for i := 0; i < 5; i++ {
+			assert.True(t, kl.Allow("vip"))
+		}
+		assert.False(t, kl.Allow("vip"))
+
+		// 已存在的 key：原地修改，已用掉的令牌不会被重置
+		assert.True(t, kl.Allow("tenant"))
+		kl.SetOverride("tenant", 10, 10)
+		assert.False(t, kl.Allow("tenant"))
+		clock.Advance(100 * time.Millisecond)
+		assert.True(t, kl.Allow("tenant"))
+
+		kl.RemoveOverride("tenant")
+		assert.Equal(t, 1, kl.Limiter("tenant").Burst())
+	})
+
+	t.Run("Wait按注入的时钟等待", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 10, Burst: 1, Clock: clock})
+		wait := func(ctx context.Context) <-chan error {
+			errc := make(chan error, 1)
+			go func() { errc <- kl.Wait(ctx, "a") }()
+			return errc
+		}
+
+		assert.NoError(t, kl.Wait(context.Background(), "a"), "桶是满的，不用等")
+
+		// 令牌用完，要等 100ms；FakeClock 推进之前一直阻塞
+		errc := wait(context.Background())
+		clock.BlockUntil(1)
+		assert.Empty(t, errc)
+		clock.Advance(100 * time.Millisecond)
+		assert.NoError(t, <-errc)
+
+		// 放弃等待时归还预留的令牌
+		ctx, cancel := context.WithCancel(context.Background())
+		errc = wait(ctx)
+		clock.BlockUntil(1)
+		cancel()
+		assert.ErrorIs(t, <-errc, context.Canceled)
+		clock.Advance(100 * time.Millisecond)
+		assert.True(t, kl.Allow("a"))
+		assert.False(t, kl.Allow("a"))
+
+		kl.SetOverride("zero", 1, 0)
+		assert.Error(t, kl.Wait(context.Background(), "zero"), "突发为 0 永远取不到令牌")
+	})
+
+	t.Run("并发安全", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 10, MaxKeys: 50, Clock: clock})
+
+		var allowed atomic.Int64
+		var wg sync.WaitGroup
+		for g := 0; g < 20; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					if kl.Allow(fmt.Sprintf("user-%d", i%10)) {
+						allowed.Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		// 10 个 key，每个 key 时间不动的情况下最多放过 burst=10 个
+		assert.Equal(t, int64(100), allowed.Load())
+	})
+}
@@ -0,0 +1,226 @@
+package ratesnippet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+限流中间件：把 Allow 的结果翻译成 HTTP 429 和标准响应头（IETF draft-ietf-httpapi-ratelimit-headers）。
+
+	RateLimit-Limit      配额（令牌桶的突发容量）
+	RateLimit-Remaining  剩余配额
+	RateLimit-Reset      多少秒后配额完全恢复
+	Retry-After          仅 429 时返回，多少秒后可以重试
+
+key 的提取方式可插拔：请求头、客户端 IP、认证主体（由前置的认证中间件写入 ctx），
+多个 KeyFunc 可以用 FirstKey 组合。RPC 场景用 UnaryServerInterceptor，形状与 gRPC 的一致。
+
+排队类算法（LeakyBucket）放行时带有 Delay：中间件和拦截器先等 Delay 再调用下游，这样突发才会被摊平；
+等待期间请求的 ctx 结束则放弃，HTTP 返回 503，RPC 返回 ctx 的错误。
+限流器实现了 Clock() 方法时用它的时钟计时，否则用 timesnippet.RealClock。
+*/
+
+// Decision 一次限流判定的结果
+type Decision struct {
+	Allowed    bool
+	Limit      int           // 配额
+	Remaining  int           // 剩余配额
+	Reset      time.Duration // 多久后配额完全恢复
+	RetryAfter time.Duration // 被拒绝时多久后可以重试
+	Delay      time.Duration // 排队类算法（LeakyBucket）放行后还需等待的时间，其余算法为 0
+}
+
+// Taker 按 key 做一次限流判定
+type Taker interface {
+	Take(key string) Decision
+}
+
+// Take 取一个令牌并返回判定结果，KeyedLimiter 因此可以直接用于中间件
+func (k *KeyedLimiter) Take(key string) Decision {
+	now := k.cfg.Clock.Now()
+	return takeToken(k.get(key, now), now)
+}
+
+// waitDelay 等待排队类算法给出的 Delay，ctx 先结束时返回 ctx.Err()
+func waitDelay(ctx context.Context, limiter Taker, d Decision) error {
+	if d.Delay <= 0 {
+		return nil
+	}
+	clock := timesnippet.RealClock
+	if c, ok := limiter.(interface{ Clock() timesnippet.Clock }); ok {
+		clock = c.Clock()
+	}
+	timer := clock.NewTimer(d.Delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds 响应头里的秒数向上取整，避免客户端提前重试
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// SetHeaders 把判定结果写入响应头
+func (d Decision) SetHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(d.Reset))
+	if !d.Allowed {
+		h.Set("Retry-After", ceilSeconds(d.RetryAfter))
+	}
+}
+
+// KeyFunc 从请求中提取限流 key，返回空串表示提取不到
+type KeyFunc func(r *http.Request) string
+
+// KeyByHeader 按请求头限流，例如 X-API-Key
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// KeyByIP 按客户端 IP 限流。只有在可信代理之后才应该打开 trustForwarded，否则客户端可以伪造 X-Forwarded-For
+func KeyByIP(trustForwarded bool) KeyFunc {
+	return func(r *http.Request) string {
+		if trustForwarded {
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				first, _, _ := strings.Cut(xff, ",")
+				return strings.TrimSpace(first)
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+type subjectKey struct{}
+
+// WithSubject 认证中间件把认证主体（用户 ID、租户等）写入 ctx
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext 取出 WithSubject 写入的认证主体
+func SubjectFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(subjectKey{}).(string)
+	return s
+}
+
+// KeyBySubject 按认证主体限流
+func KeyBySubject() KeyFunc {
+	return func(r *http.Request) string {
+		return SubjectFromContext(r.Context())
+	}
+}
+
+// FirstKey 依次尝试，返回第一个非空 key，例如 FirstKey(KeyBySubject(), KeyByIP(false))
+func FirstKey(funcs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, f := range funcs {
+			if k := f(r); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+// Middleware 限流中间件，超限返回 429，排队等待 Delay 时请求被取消返回 503。提取不到 key 的请求共用空串这一个桶
+func Middleware(limiter Taker, key KeyFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := limiter.Take(key(r))
+		d.SetHeaders(w.Header())
+		if !d.Allowed {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		if err := waitDelay(r.Context(), limiter, d); err != nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ErrRateLimited 被限流的哨兵错误，RPC 框架可以把它映射为 RESOURCE_EXHAUSTED
+var ErrRateLimited = errors.New("ratesnippet: rate limited")
+
+// RateLimitError 携带判定结果，调用方据此设置重试时间
+type RateLimitError struct {
+	Key      string
+	Decision Decision
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("ratesnippet: rate limited key=%q, retry after %v", e.Key, e.Decision.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// UnaryServerInfo 与 grpc.UnaryServerInfo 对应
+type UnaryServerInfo struct {
+	FullMethod string
+}
+
+// UnaryHandler 与 grpc.UnaryHandler 对应
+type UnaryHandler func(ctx context.Context, req any) (any, error)
+
+// UnaryServerInterceptor 与 grpc.UnaryServerInterceptor 形状一致，接入 gRPC 时只需做类型转换
+type UnaryServerInterceptor func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error)
+
+// UnaryKeyFunc 从 RPC 调用中提取限流 key
+type UnaryKeyFunc func(ctx context.Context, info *UnaryServerInfo, req any) string
+
+// UnaryKeyBySubject 按认证主体限流
+func UnaryKeyBySubject(ctx context.Context, _ *UnaryServerInfo, _ any) string {
+	return SubjectFromContext(ctx)
+}
+
+// UnaryKeyByMethod 按方法名限流，保护单个昂贵接口
+func UnaryKeyByMethod(_ context.Context, info *UnaryServerInfo, _ any) string {
+	return info.FullMethod
+}
+
+// UnaryRateLimit 限流拦截器，超限时返回 *RateLimitError 而不调用 handler；
+// 需要排队时等 Delay 后再调用，等待期间 ctx 结束则返回 ctx.Err()
+func UnaryRateLimit(limiter Taker, key UnaryKeyFunc) UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		k := key(ctx, info, req)
+		d := limiter.Take(k)
+		if !d.Allowed {
+			return nil, &RateLimitError{Key: k, Decision: d}
+		}
+		if err := waitDelay(ctx, limiter, d); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
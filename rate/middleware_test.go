@@ -0,0 +1,213 @@
+package ratesnippet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// leakyTaker 把不区分 key 的 LeakyBucket 包成 Taker，并提供 Clock() 让中间件用同一个时钟等待 Delay
+type leakyTaker struct {
+	*LeakyBucket
+}
+
+func (l leakyTaker) Take(string) Decision     { return l.LeakyBucket.Take() }
+func (l leakyTaker) Clock() timesnippet.Clock { return l.clock }
+
+func TestMiddleware(t *testing.T) {
+	t.Run("429与响应头", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 2, Burst: 2, Clock: clock})
+		h := Middleware(kl, KeyByHeader("X-API-Key"), okHandler())
+
+		do := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-API-Key", "k1")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Reset")) // 缺 1 个令牌，0.5s 向上取整
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+
+		rec = do()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+		rec = do()
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+		clock.Advance(500 * time.Millisecond)
+		assert.Equal(t, http.StatusOK, do().Code)
+	})
+
+	t.Run("按IP", func(t *testing.T) {
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 1, Clock: timesnippet.NewFakeClock(clockStart)})
+		h := Middleware(kl, KeyByIP(false), okHandler())
+
+		do := func(remote string) int {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = remote
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		assert.Equal(t, http.StatusOK, do("10.0.0.1:1234"))
+		assert.Equal(t, http.StatusTooManyRequests, do("10.0.0.1:5678"), "同一 IP 不同端口")
+		assert.Equal(t, http.StatusOK, do("10.0.0.2:1234"))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		assert.Equal(t, "203.0.113.7", KeyByIP(true)(req))
+		assert.Equal(t, "192.0.2.1", KeyByIP(false)(req))
+	})
+
+	t.Run("认证主体优先，回退到IP", func(t *testing.T) {
+		key := FirstKey(KeyBySubject(), KeyByIP(false))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Equal(t, "192.0.2.1", key(req))
+		req = req.WithContext(WithSubject(req.Context(), "user-42"))
+		assert.Equal(t, "user-42", key(req))
+	})
+
+	t.Run("真实HTTP服务", func(t *testing.T) {
+		kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 3, Clock: timesnippet.NewFakeClock(clockStart)})
+		srv := httptest.NewServer(Middleware(kl, KeyByIP(false), okHandler()))
+		defer srv.Close()
+
+		codes := make([]int, 0, 5)
+		for i := 0; i < 5; i++ {
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			codes = append(codes, resp.StatusCode)
+		}
+		assert.Equal(t, []int{200, 200, 200, 429, 429}, codes)
+	})
+
+	t.Run("漏桶排队等待Delay后才调用下游", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		served := make(chan struct{}, 3)
+		h := Middleware(leakyTaker{NewLeakyBucket(10, 3, clock)}, KeyByIP(false), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served <- struct{}{}
+		}))
+
+		serve := func(ctx context.Context) <-chan int {
+			code := make(chan int, 1)
+			go func() {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+				code <- rec.Code
+			}()
+			return code
+		}
+
+		// 第一个请求不用排队
+		assert.Equal(t, http.StatusOK, <-serve(context.Background()))
+		<-served
+
+		// 第二个排在 100ms 之后：定时器到期前下游不会被调用
+		code := serve(context.Background())
+		clock.BlockUntil(1)
+		clock.Advance(99 * time.Millisecond)
+		assert.Empty(t, served, "Delay 到期前不调用下游")
+		clock.Advance(time.Millisecond)
+		assert.Equal(t, http.StatusOK, <-code)
+		<-served
+
+		// 排队期间请求被取消：返回 503，不调用下游
+		ctx, cancel := context.WithCancel(context.Background())
+		code = serve(ctx)
+		clock.BlockUntil(1)
+		cancel()
+		assert.Equal(t, http.StatusServiceUnavailable, <-code)
+		assert.Empty(t, served)
+	})
+}
+
+func TestUnaryRateLimit(t *testing.T) {
+	kl := NewKeyedLimiter(KeyedLimiterConfig{Rate: 1, Burst: 1, Clock: timesnippet.NewFakeClock(clockStart)})
+	interceptor := UnaryRateLimit(kl, UnaryKeyBySubject)
+	info := &UnaryServerInfo{FullMethod: "/user.UserService/Get"}
+
+	calls := 0
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls++
+		return "ok", nil
+	}
+
+	ctx := WithSubject(context.Background(), "alice")
+	resp, err := interceptor(ctx, nil, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	var rle *RateLimitError
+	if assert.True(t, errors.As(err, &rle)) {
+		assert.Equal(t, "alice", rle.Key)
+		assert.Equal(t, time.Second, rle.Decision.RetryAfter)
+	}
+	assert.Equal(t, 1, calls, "被限流时不调用 handler")
+
+	_, err = interceptor(WithSubject(context.Background(), "bob"), nil, info, handler)
+	assert.NoError(t, err)
+
+	t.Run("漏桶排队等待Delay后才调用handler", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		interceptor := UnaryRateLimit(leakyTaker{NewLeakyBucket(10, 3, clock)}, UnaryKeyByMethod)
+		called := make(chan struct{}, 3)
+		handler := func(ctx context.Context, req any) (any, error) {
+			called <- struct{}{}
+			return "ok", nil
+		}
+		call := func(ctx context.Context) <-chan error {
+			errc := make(chan error, 1)
+			go func() {
+				_, err := interceptor(ctx, nil, info, handler)
+				errc <- err
+			}()
+			return errc
+		}
+
+		assert.NoError(t, <-call(context.Background()))
+		<-called
+
+		errc := call(context.Background())
+		clock.BlockUntil(1)
+		assert.Empty(t, called, "Delay 到期前不调用 handler")
+		clock.Advance(100 * time.Millisecond)
+		assert.NoError(t, <-errc)
+		<-called
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errc = call(ctx)
+		clock.BlockUntil(1)
+		cancel()
+		assert.ErrorIs(t, <-errc, context.Canceled)
+		assert.Empty(t, called)
+	})
+}
@@ -1,37 +1,68 @@
 package ratesnippet
 
 import (
-	"fmt"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/time/rate"
+
+	metersnippet "github.com/A0dongq1N/golang_snippet/meter"
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
 )
 
-//TODO 2025年09月14日00:46:08 还没懂
+// 令牌桶：rate.NewLimiter(r, b) 桶容量 b，每秒匀速放入 r 个令牌，Allow 取一个令牌，桶空则返回 false。
+// AllowN/TokensAt 等带时间参数的方法可以传入指定时刻，用它们就能在不 sleep 的情况下确定性地观察令牌桶。
 
 func TestRate(t *testing.T) {
 	lim := rate.NewLimiter(100, 10) // 100 QPS，允许 10 的瞬时突发
-	tick := time.NewTicker(time.Second)
-	defer tick.Stop()
+	clock := timesnippet.NewFakeClock(clockStart)
+	start := clock.Now()
+
+	// 初始时桶是满的：同一时刻能连续放过 10 个请求，第 11 个被拒绝
+	for i := 0; i < 10; i++ {
+		assert.True(t, lim.AllowN(start, 1))
+	}
+	assert.False(t, lim.AllowN(start, 1))
+	t.Logf("当前桶内令牌 = %.2f", lim.TokensAt(start))
+
+	// 100 QPS 即每 10ms 生成一个令牌
+	clock.Advance(5 * time.Millisecond)
+	assert.InDelta(t, 0.5, lim.TokensAt(clock.Now()), 1e-9)
+	clock.Advance(5 * time.Millisecond)
+	assert.True(t, lim.AllowN(clock.Now(), 1))
+	assert.False(t, lim.AllowN(clock.Now(), 1))
 
-	count := 0
-	for {
+	// 空闲足够久桶会重新装满，但不会超过容量 10
+	clock.Advance(time.Hour)
+	assert.Equal(t, 10.0, lim.TokensAt(clock.Now()))
+}
+
+// 模拟原来的忙循环：每 1ms 的 tick 尽可能多地调用 Allow，放过的请求记到 Meter 上，每秒的 tick 读取一次滑动窗口速率
+func TestRateQPS(t *testing.T) {
+	lim := rate.NewLimiter(100, 10)
+	clock := timesnippet.NewFakeClock(clockStart)
+	qps := metersnippet.NewMeter(metersnippet.MeterConfig{Name: "allowed", Window: time.Second, Clock: clock})
+	poll := clock.NewTicker(time.Millisecond)
+	defer poll.Stop()
+	report := clock.NewTicker(time.Second)
+	defer report.Stop()
+
+	var counts []float64
+	for len(counts) < 3 {
+		clock.Advance(time.Millisecond)
 		select {
-		case <-tick.C:
-			fmt.Println("qps:", count)
-			count = 0
-		// 没有匹配到case就会执行default
+		case <-report.C():
+			counts = append(counts, qps.Rate())
 		default:
-			// 如果令牌桶中的牌数量小于100就++，否则就执行else,因为很快就执行到了100，所以大部分时间都是sleep,所以定时输出100
-			if lim.Allow() {
-				count++
-			} else {
-				fmt.Printf("当前桶内令牌 = %.2f\n", lim.TokensAt(time.Now()))
-				// 丢弃或小睡一会儿
-				time.Sleep(1 * time.Millisecond)
-			}
+		}
+		now := <-poll.C()
+		for lim.AllowN(now, 1) {
+			qps.Mark(1)
 		}
 	}
+	t.Log(qps.Snapshot())
 
+	// 第一秒：初始突发 10 个 + 第 1ms 到第 999ms 生成的 99 个；之后稳定在 100
+	assert.Equal(t, []float64{109, 100, 100}, counts)
 }
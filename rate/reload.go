@@ -0,0 +1,391 @@
+package ratesnippet
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+限流规则热加载：规则写在 YAML/JSON 文件里，按 key 的通配模式匹配（path.Match 语法，第一条匹配的生效）。
+
+	rules:
+	  - pattern: "vip:*"
+	    rate: 100
+	    burst: 20
+	  - pattern: "ip:*"
+	    algorithm: sliding_window
+	    rate: 10
+	    window: 1m
+
+- RuleLimiter.Update 在一把锁内整体替换规则，请求看到的要么全是旧规则、要么全是新规则
+- 令牌桶规则更新时对已有的 rate.Limiter 调用 SetLimitAt/SetBurstAt，桶里现有的令牌保留，不会因为改配置被清零或重新装满
+- 算法变了或者不是令牌桶的，丢弃旧状态，下次请求按新规则重新创建
+- 与 KeyedLimiter 一样，空闲超过 TTL 的 key 被淘汰，key 数超过 MaxKeys 时淘汰最久未访问的（LRU）
+- RuleWatcher 轮询文件内容（sha256），变化后解析校验；文件不合法时报错并继续使用上一份合法配置
+*/
+
+// 支持的算法
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmGCRA          = "gcra"
+	AlgorithmLeakyBucket   = "leaky_bucket"
+	AlgorithmFixedWindow   = "fixed_window"
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmSlidingLog    = "sliding_log"
+)
+
+// Rule 一条限流规则
+type Rule struct {
+	Pattern   string  `json:"pattern" yaml:"pattern"`
+	Algorithm string  `json:"algorithm,omitempty" yaml:"algorithm,omitempty"` // 默认 token_bucket
+	Rate      float64 `json:"rate" yaml:"rate"`                               // 每秒请求数
+	Burst     int     `json:"burst,omitempty" yaml:"burst,omitempty"`         // 令牌桶、GCRA 的突发，漏桶的队列长度
+	Window    string  `json:"window,omitempty" yaml:"window,omitempty"`       // 窗口类算法的窗口长度，默认 1s，窗口内上限为 rate×window
+}
+
+// RuleConfig 规则文件内容
+type RuleConfig struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// ParseRuleConfig 按扩展名（.json/.yaml/.yml）解析规则并校验
+func ParseRuleConfig(data []byte, ext string) (RuleConfig, error) {
+	var cfg RuleConfig
+	switch strings.ToLower(ext) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return RuleConfig{}, fmt.Errorf("parse json rules: %w", err)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil {
+			return RuleConfig{}, fmt.Errorf("parse yaml rules: %w", err)
+		}
+	default:
+		return RuleConfig{}, fmt.Errorf("unsupported rule file extension %q", ext)
+	}
+	if _, err := compileRules(cfg); err != nil {
+		return RuleConfig{}, err
+	}
+	return cfg, nil
+}
+
+// LoadRuleFile 读取并解析规则文件
+func LoadRuleFile(name string) (RuleConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return RuleConfig{}, err
+	}
+	return ParseRuleConfig(data, filepath.Ext(name))
+}
+
+type compiledRule struct {
+	Rule
+	window time.Duration
+}
+
+func compileRules(cfg RuleConfig) ([]compiledRule, error) {
+	var errs []error
+	rules := make([]compiledRule, 0, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		c, err := compileRule(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d (%q): %w", i, r.Pattern, err))
+			continue
+		}
+		rules = append(rules, c)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func compileRule(r Rule) (compiledRule, error) {
+	if r.Pattern == "" {
+		return compiledRule{}, errors.New("empty pattern")
+	}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return compiledRule{}, err
+	}
+	if r.Algorithm == "" {
+		r.Algorithm = AlgorithmTokenBucket
+	}
+	if r.Rate <= 0 {
+		return compiledRule{}, fmt.Errorf("rate must be positive, got %v", r.Rate)
+	}
+
+	c := compiledRule{Rule: r, window: time.Second}
+	switch r.Algorithm {
+	case AlgorithmTokenBucket, AlgorithmGCRA, AlgorithmLeakyBucket:
+		if r.Burst <= 0 {
+			return compiledRule{}, fmt.Errorf("burst must be positive for %s, got %d", r.Algorithm, r.Burst)
+		}
+	case AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmSlidingLog:
+		if r.Window != "" {
+			d, err := time.ParseDuration(r.Window)
+			if err != nil {
+				return compiledRule{}, err
+			}
+			if d <= 0 {
+				return compiledRule{}, fmt.Errorf("window must be positive, got %v", d)
+			}
+			c.window = d
+		}
+		if c.windowLimit() < 1 {
+			return compiledRule{}, fmt.Errorf("rate×window must allow at least one request, got %v×%v", r.Rate, c.window)
+		}
+	default:
+		return compiledRule{}, fmt.Errorf("unknown algorithm %q", r.Algorithm)
+	}
+	return c, nil
+}
+
+func (c compiledRule) windowLimit() int {
+	return int(c.Rate * c.window.Seconds())
+}
+
+// RuleLimiterConfig RuleLimiter 的配置，零值字段使用默认值
+type RuleLimiterConfig struct {
+	TTL     time.Duration     // key 空闲多久后淘汰，默认 10m
+	MaxKeys int               // 最多保留多少个 key，默认 10000
+	Clock   timesnippet.Clock // 为空时使用 timesnippet.RealClock
+}
+
+// ruleEntry 某个 key 的限流状态
+type ruleEntry struct {
+	key      string
+	rule     compiledRule
+	token    *rate.Limiter // 令牌桶算法时非空，更新规则时原地调整
+	lim      Limiter
+	lastSeen time.Time
+}
+
+// RuleLimiter 按规则限流，规则可以在运行时整体替换，并发安全
+type RuleLimiter struct {
+	cfg RuleLimiterConfig
+
+	mu      sync.Mutex
+	rules   []compiledRule
+	entries map[string]*list.Element // value: *ruleEntry
+	lru     *list.List               // 表头最近访问，表尾最久未访问
+}
+
+// NewRuleLimiter 用初始规则创建
+func NewRuleLimiter(rules RuleConfig, cfg RuleLimiterConfig) (*RuleLimiter, error) {
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 10000
+	}
+	cfg.Clock = clockOrSystem(cfg.Clock)
+	return &RuleLimiter{cfg: cfg, rules: compiled, entries: make(map[string]*list.Element), lru: list.New()}, nil
+}
+
+// Rules 当前生效的规则
+func (l *RuleLimiter) Rules() []Rule {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rules := make([]Rule, len(l.rules))
+	for i, r := range l.rules {
+		rules[i] = r.Rule
+	}
+	return rules
+}
+
+// Clock 限流器使用的时钟，Middleware 和 UnaryRateLimit 用它等待漏桶规则给出的 Delay
+func (l *RuleLimiter) Clock() timesnippet.Clock {
+	return l.cfg.Clock
+}
+
+// Len 当前保留的 key 数量（会先淘汰过期 key）
+func (l *RuleLimiter) Len() int {
+	now := l.cfg.Clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.evictLocked(now)
+	return len(l.entries)
+}
+
+// Take 实现 Taker。没有匹配规则的 key 不限流
+func (l *RuleLimiter) Take(key string) Decision {
+	now := l.cfg.Clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var e *ruleEntry
+	if el, ok := l.entries[key]; ok {
+		e = el.Value.(*ruleEntry)
+		l.lru.MoveToFront(el)
+	} else {
+		rule, ok := matchRule(l.rules, key)
+		if !ok {
+			return Decision{Allowed: true}
+		}
+		e = l.newEntry(key, rule)
+		l.entries[key] = l.lru.PushFront(e)
+	}
+	e.lastSeen = now
+	l.evictLocked(now)
+	if e.token != nil {
+		return takeToken(e.token, now)
+	}
+	return e.lim.Take()
+}
+
+// Update 校验并整体替换规则，校验失败时保持原规则不变
+func (l *RuleLimiter) Update(cfg RuleConfig) error {
+	rules, err := compileRules(cfg)
+	if err != nil {
+		return err
+	}
+
+	now := l.cfg.Clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = rules
+	for key, el := range l.entries {
+		e := el.Value.(*ruleEntry)
+		rule, ok := matchRule(rules, key)
+		switch {
+		case !ok:
+			l.removeLocked(el)
+		case e.token != nil && rule.Algorithm == AlgorithmTokenBucket:
+			// 原地调整，保留当前令牌
+			e.token.SetLimitAt(now, rate.Limit(rule.Rate))
+			e.token.SetBurstAt(now, rule.Burst)
+			e.rule = rule
+		case rule != e.rule:
+			l.removeLocked(el)
+		}
+	}
+	return nil
+}
+
+// evictLocked 与 KeyedLimiter 相同：链表按访问时间有序，从表尾淘汰
+func (l *RuleLimiter) evictLocked(now time.Time) {
+	for el := l.lru.Back(); el != nil; el = l.lru.Back() {
+		e := el.Value.(*ruleEntry)
+		expired := now.Sub(e.lastSeen) >= l.cfg.TTL
+		overflow := l.lru.Len() > l.cfg.MaxKeys
+		if !expired && !overflow {
+			return
+		}
+		l.removeLocked(el)
+	}
+}
+
+func (l *RuleLimiter) removeLocked(el *list.Element) {
+	l.lru.Remove(el)
+	delete(l.entries, el.Value.(*ruleEntry).key)
+}
+
+func matchRule(rules []compiledRule, key string) (compiledRule, bool) {
+	for _, r := range rules {
+		if ok, _ := path.Match(r.Pattern, key); ok {
+			return r, true
+		}
+	}
+	return compiledRule{}, false
+}
+
+func (l *RuleLimiter) newEntry(key string, r compiledRule) *ruleEntry {
+	e := &ruleEntry{key: key, rule: r}
+	switch r.Algorithm {
+	case AlgorithmTokenBucket:
+		e.token = rate.NewLimiter(rate.Limit(r.Rate), r.Burst)
+	case AlgorithmGCRA:
+		e.lim = NewGCRA(r.Rate, r.Burst, l.cfg.Clock)
+	case AlgorithmLeakyBucket:
+		e.lim = NewLeakyBucket(r.Rate, r.Burst, l.cfg.Clock)
+	case AlgorithmFixedWindow:
+		e.lim = NewFixedWindow(r.windowLimit(), r.window, l.cfg.Clock)
+	case AlgorithmSlidingWindow:
+		e.lim = NewSlidingWindowCounter(r.windowLimit(), r.window, l.cfg.Clock)
+	case AlgorithmSlidingLog:
+		e.lim = NewSlidingWindowLog(r.windowLimit(), r.window, l.cfg.Clock)
+	}
+	return e
+}
+
+// RuleWatcher 监视规则文件，内容变化时更新 RuleLimiter
+type RuleWatcher struct {
+	Path     string
+	Target   *RuleLimiter
+	OnReload func(cfg RuleConfig) // 成功加载新规则后回调
+	OnError  func(err error)      // 读取或校验失败时回调，此时继续使用旧规则
+	Clock    timesnippet.Clock    // 轮询用的时钟，为空时使用 timesnippet.RealClock
+
+	mu       sync.Mutex
+	lastHash [sha256.Size]byte
+	seen     bool
+}
+
+// Reload 检查一次文件，内容变化且合法时应用新规则，返回是否应用了新规则。
+// 同一份不合法的内容只报一次错
+func (w *RuleWatcher) Reload() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := os.ReadFile(w.Path)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(data)
+	if w.seen && sum == w.lastHash {
+		return false, nil
+	}
+	w.lastHash, w.seen = sum, true
+
+	cfg, err := ParseRuleConfig(data, filepath.Ext(w.Path))
+	if err == nil {
+		err = w.Target.Update(cfg)
+	}
+	if err != nil {
+		return false, fmt.Errorf("reload %s: %w", w.Path, err)
+	}
+	if w.OnReload != nil {
+		w.OnReload(cfg)
+	}
+	return true, nil
+}
+
+// Watch 每隔 interval 检查一次文件，直到 ctx 结束
+func (w *RuleWatcher) Watch(ctx context.Context, interval time.Duration) {
+	ticker := clockOrSystem(w.Clock).NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Reload(); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package ratesnippet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+const yamlRules = `
+rules:
+  - pattern: "vip:*"
+    rate: 100
+    burst: 20
+  - pattern: "ip:*"
+    algorithm: sliding_window
+    rate: 10
+    window: 1m
+`
+
+const jsonRules = `{"rules": [
+  {"pattern": "vip:*", "rate": 100, "burst": 20},
+  {"pattern": "ip:*", "algorithm": "sliding_window", "rate": 10, "window": "1m"}
+]}`
+
+func TestParseRuleConfig(t *testing.T) {
+	t.Run("yaml和json等价", func(t *testing.T) {
+		y, err := ParseRuleConfig([]byte(yamlRules), ".yaml")
+		assert.NoError(t, err)
+		j, err := ParseRuleConfig([]byte(jsonRules), ".json")
+		assert.NoError(t, err)
+		assert.Equal(t, y, j)
+		assert.Len(t, y.Rules, 2)
+	})
+
+	invalid := map[string]string{
+		"未知算法":   `{"rules": [{"pattern": "*", "algorithm": "magic", "rate": 1, "burst": 1}]}`,
+		"速率为0":   `{"rules": [{"pattern": "*", "burst": 1}]}`,
+		"缺少突发":   `{"rules": [{"pattern": "*", "rate": 1}]}`,
+		"模式不合法":  `{"rules": [{"pattern": "[", "rate": 1, "burst": 1}]}`,
+		"窗口不合法":  `{"rules": [{"pattern": "*", "algorithm": "fixed_window", "rate": 1, "window": "soon"}]}`,
+		"窗口内不足1": `{"rules": [{"pattern": "*", "algorithm": "fixed_window", "rate": 1, "window": "100ms"}]}`,
+		"未知字段":   `{"rules": [{"pattern": "*", "rate": 1, "burst": 1, "brust": 2}]}`,
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseRuleConfig([]byte(data), ".json")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRuleLimiter(t *testing.T) {
+	t.Run("按模式匹配", func(t *testing.T) {
+		cfg, _ := ParseRuleConfig([]byte(yamlRules), ".yaml")
+		l, err := NewRuleLimiter(cfg, RuleLimiterConfig{Clock: timesnippet.NewFakeClock(clockStart)})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 20, l.Take("vip:alice").Limit)
+		assert.Equal(t, 600, l.Take("ip:1.2.3.4").Limit)
+		d := l.Take("other")
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 0, d.Limit, "没有匹配规则不限流")
+	})
+
+	t.Run("更新保留令牌", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l, _ := NewRuleLimiter(RuleConfig{Rules: []Rule{{Pattern: "*", Rate: 1, Burst: 10}}}, RuleLimiterConfig{Clock: clock})
+		for i := 0; i < 8; i++ {
+			assert.True(t, l.Take("k").Allowed)
+		}
+
+		// 调大突发不会把桶重新装满，剩下的还是 2 个
+		assert.NoError(t, l.Update(RuleConfig{Rules: []Rule{{Pattern: "*", Rate: 10, Burst: 20}}}))
+		assert.True(t, l.Take("k").Allowed)
+		assert.True(t, l.Take("k").Allowed)
+		d := l.Take("k")
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 20, d.Limit)
+		assert.Equal(t, 100*time.Millisecond, d.RetryAfter, "新速率 10/s 立即生效")
+
+		clock.Advance(time.Second)
+		assert.Equal(t, 10, l.Take("k").Remaining+1)
+	})
+
+	t.Run("算法变化时重建", func(t *testing.T) {
+		l, _ := NewRuleLimiter(RuleConfig{Rules: []Rule{{Pattern: "*", Rate: 1, Burst: 1}}}, RuleLimiterConfig{Clock: timesnippet.NewFakeClock(clockStart)})
+		assert.True(t, l.Take("k").Allowed)
+		assert.False(t, l.Take("k").Allowed)
+
+		assert.NoError(t, l.Update(RuleConfig{Rules: []Rule{{Pattern: "*", Algorithm: AlgorithmFixedWindow, Rate: 2}}}))
+		assert.True(t, l.Take("k").Allowed)
+		assert.True(t, l.Take("k").Allowed)
+		assert.False(t, l.Take("k").Allowed)
+	})
+
+	t.Run("淘汰空闲和超出上限的 key", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l, _ := NewRuleLimiter(RuleConfig{Rules: []Rule{{Pattern: "ip:*", Rate: 1, Burst: 1}}},
+			RuleLimiterConfig{TTL: time.Minute, MaxKeys: 2, Clock: clock})
+		assert.True(t, l.Take("ip:a").Allowed)
+		assert.False(t, l.Take("ip:a").Allowed)
+		l.Take("ip:b")
+		l.Take("other") // 没有匹配规则的 key 不占位置
+		assert.Equal(t, 2, l.Len())
+
+		l.Take("ip:a")
+		assert.True(t, l.Take("ip:c").Allowed) // 超过 MaxKeys，淘汰最久未访问的 b
+		assert.True(t, l.Take("ip:b").Allowed, "b 被淘汰过，重新创建，这次淘汰的是 a")
+		assert.True(t, l.Take("ip:a").Allowed, "a 被淘汰过，桶是满的")
+		assert.Equal(t, 2, l.Len())
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, 0, l.Len())
+		assert.True(t, l.Take("ip:a").Allowed, "过期后重新创建，桶是满的")
+	})
+
+	t.Run("漏桶规则经过中间件排队", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		l, _ := NewRuleLimiter(RuleConfig{Rules: []Rule{{Pattern: "*", Algorithm: AlgorithmLeakyBucket, Rate: 10, Burst: 2}}},
+			RuleLimiterConfig{Clock: clock})
+		served := make(chan struct{}, 3)
+		h := Middleware(l, KeyByHeader("X-API-Key"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served <- struct{}{}
+		}))
+		serve := func() <-chan int {
+			code := make(chan int, 1)
+			go func() {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-API-Key", "k")
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				code <- rec.Code
+			}()
+			return code
+		}
+
+		assert.Equal(t, http.StatusOK, <-serve())
+		<-served
+
+		// 第二个排在 100ms 之后，第三个超出队列容量直接 429
+		second := serve()
+		clock.BlockUntil(1)
+		assert.Equal(t, http.StatusTooManyRequests, <-serve())
+		assert.Empty(t, served, "排队中的请求还没有到达下游")
+
+		clock.Advance(100 * time.Millisecond)
+		assert.Equal(t, http.StatusOK, <-second)
+		<-served
+	})
+
+	t.Run("不合法的规则不生效", func(t *testing.T) {
+		l, _ := NewRuleLimiter(RuleConfig{Rules: []Rule{{Pattern: "*", Rate: 1, Burst: 1}}}, RuleLimiterConfig{Clock: timesnippet.NewFakeClock(clockStart)})
+		assert.Error(t, l.Update(RuleConfig{Rules: []Rule{{Pattern: "*", Rate: -1, Burst: 1}}}))
+		assert.Equal(t, []Rule{{Pattern: "*", Algorithm: AlgorithmTokenBucket, Rate: 1, Burst: 1}}, l.Rules())
+	})
+}
+
+func TestRuleWatcher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rules.yaml")
+	write := func(s string) { assert.NoError(t, os.WriteFile(file, []byte(s), 0o644)) }
+
+	l, _ := NewRuleLimiter(RuleConfig{}, RuleLimiterConfig{Clock: timesnippet.NewFakeClock(clockStart)})
+	w := &RuleWatcher{Path: file, Target: l}
+
+	t.Run("手动检查", func(t *testing.T) {
+		write(yamlRules)
+		changed, err := w.Reload()
+		assert.NoError(t, err)
+		assert.True(t, changed)
+		assert.Len(t, l.Rules(), 2)
+
+		changed, err = w.Reload()
+		assert.NoError(t, err)
+		assert.False(t, changed, "内容没变")
+
+		write("rules: [{pattern: '*', rate: 0}]")
+		_, err = w.Reload()
+		assert.Error(t, err)
+		assert.Len(t, l.Rules(), 2, "继续使用上一份合法配置")
+		_, err = w.Reload()
+		assert.NoError(t, err, "同一份错误内容只报一次")
+	})
+
+	t.Run("后台轮询", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		reloads, errs := make(chan RuleConfig, 1), make(chan error, 1)
+		w.OnReload = func(cfg RuleConfig) { reloads <- cfg }
+		w.OnError = func(err error) { errs <- err }
+		w.Clock = clock
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			w.Watch(ctx, time.Second)
+			close(done)
+		}()
+		clock.BlockUntil(1) // ticker 已创建；启动时的那次检查早于还是晚于下面的写入，结果都一样
+
+		write("rules: [{pattern: 'user:*', rate: 5, burst: 5}]")
+		clock.Advance(time.Second)
+		<-reloads
+		assert.Equal(t, []Rule{{Pattern: "user:*", Algorithm: AlgorithmTokenBucket, Rate: 5, Burst: 5}}, l.Rules())
+
+		write("rules: [")
+		clock.Advance(time.Second)
+		assert.Error(t, <-errs)
+		assert.Equal(t, []Rule{{Pattern: "user:*", Algorithm: AlgorithmTokenBucket, Rate: 5, Burst: 5}}, l.Rules())
+
+		cancel()
+		<-done
+	})
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
+
+	metersnippet "github.com/A0dongq1N/golang_snippet/meter"
 )
 
 // getEnvWithDefault 获取环境变量，如果不存在则返回默认值
@@ -92,3 +95,43 @@ func TestRedisPipeline(t *testing.T) {
 	// 清理测试数据
 	rdb.Del(ctx, "key1", "key2", "counter")
 }
+
+// 用 Meter 对比逐条执行和 Pipeline 批量执行的吞吐与延迟
+func TestRedisPipelineMeter(t *testing.T) {
+	rdb := createRedisClient()
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := rdb.Ping(ctx).Result(); err != nil {
+		t.Skipf("Redis server not available: %v", err)
+	}
+	defer rdb.Del(ctx, "meter:counter")
+
+	const n, batch = 1000, 100
+	single := metersnippet.NewMeter(metersnippet.MeterConfig{Name: "redis.single"})
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		assert.NoError(t, rdb.Incr(ctx, "meter:counter").Err())
+		single.Since(start)
+	}
+
+	// Pipeline 每批 batch 条命令，按命令数计吞吐，按批记录延迟
+	pipelined := metersnippet.NewMeter(metersnippet.MeterConfig{Name: "redis.pipeline"})
+	for i := 0; i < n/batch; i++ {
+		start := time.Now()
+		pipe := rdb.Pipeline()
+		for j := 0; j < batch; j++ {
+			pipe.Incr(ctx, "meter:counter")
+		}
+		_, err := pipe.Exec(ctx)
+		assert.NoError(t, err)
+		pipelined.ObserveN(batch, time.Since(start))
+	}
+
+	var report strings.Builder
+	assert.NoError(t, metersnippet.WriteText(&report, single.Snapshot(), pipelined.Snapshot()))
+	log.Print("\n" + report.String())
+	assert.Equal(t, int64(n), pipelined.Count())
+}
@@ -0,0 +1,227 @@
+package timesnippet
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Clock 抽象时间来源，业务代码依赖 Clock 而不是直接调用 time 包，测试时换成 FakeClock：
+
+	clock := NewFakeClock(start)
+	ticker := clock.NewTicker(2 * time.Second)
+	clock.Advance(5 * time.Second) // 同步触发到期的定时器，不真正等待
+	<-ticker.C()
+
+- FakeClock.Advance 按到期时间顺序逐个触发定时器，触发时 Now() 等于该定时器的到期时刻
+- 和 time.Ticker 一样，通道缓冲为 1，读取不及时的 tick 会被丢弃
+- Sleep/After 在别的 goroutine 里阻塞时，用 BlockUntil 等它们注册好定时器再 Advance，避免竞态
+*/
+
+// Clock 时间来源
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 对应 *time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker 对应 *time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// RealClock 使用 time 包的真实时钟
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// FakeClock 手动推进的时钟，并发安全
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond // 有定时器注册时广播，供 BlockUntil 等待
+	now    time.Time
+	timers []*fakeTimer // 未触发的定时器
+}
+
+// NewFakeClock 从 start 开始的假时钟
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep 阻塞到别的 goroutine 把时间推进 d 为止
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.scheduleLocked(t, d, 0)
+	c.mu.Unlock()
+	return t
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("timesnippet: non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.scheduleLocked(t, d, d)
+	c.mu.Unlock()
+	return fakeTicker{t}
+}
+
+// Advance 推进时间 d，期间到期的定时器按时间顺序触发
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].when.After(target) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+		select {
+		case t.ch <- t.when:
+		default: // 上一次的值还没被读走，丢弃
+		}
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+			c.insertLocked(t)
+		} else {
+			t.active = false
+		}
+	}
+	c.now = target
+}
+
+// Set 把时间推进到 t，t 早于当前时间时什么也不做
+func (c *FakeClock) Set(t time.Time) {
+	if d := t.Sub(c.Now()); d > 0 {
+		c.Advance(d)
+	}
+}
+
+// BlockUntil 阻塞直到至少有 n 个未触发的定时器（包括 Sleep、After 内部的）
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// scheduleLocked d 之后触发，period>0 时之后每隔 period 触发一次；d<=0 立即触发
+func (c *FakeClock) scheduleLocked(t *fakeTimer, d, period time.Duration) {
+	t.when, t.period, t.active = c.now.Add(d), period, true
+	if d <= 0 && period == 0 {
+		select {
+		case t.ch <- c.now:
+		default:
+		}
+		t.active = false
+		return
+	}
+	c.insertLocked(t)
+	c.cond.Broadcast()
+}
+
+func (c *FakeClock) insertLocked(t *fakeTimer) {
+	// 同一时刻到期的按注册顺序触发
+	i := sort.Search(len(c.timers), func(i int) bool { return c.timers[i].when.After(t.when) })
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+}
+
+func (c *FakeClock) removeLocked(t *fakeTimer) bool {
+	if !t.active {
+		return false
+	}
+	t.active = false
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	ch     chan time.Time
+	when   time.Time
+	period time.Duration
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.removeLocked(t)
+	t.clock.scheduleLocked(t, d, t.period)
+	return active
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.ch }
+func (t fakeTicker) Stop()               { t.t.Stop() }
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("timesnippet: non-positive interval for Ticker.Reset")
+	}
+	c := t.t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(t.t)
+	c.scheduleLocked(t.t, d, d)
+}
@@ -3,25 +3,113 @@ package timesnippet
 import (
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
+var start = time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+
 func TestTime(t *testing.T) {
+	clock := NewFakeClock(start)
+
 	// 周期性触发
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := clock.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
 	//只触发一次
-	timer := time.NewTimer(5 * time.Second)
+	timer := clock.NewTimer(5 * time.Second)
 	defer timer.Stop()
 
-	for {
+	// 原来的循环：有 tick 走定时分支，否则 sleep 1s；这里跑 10 秒
+	var ticks, timeouts, idles int
+	for i := 0; i < 10; i++ {
 		select {
-		case <-ticker.C:
-			t.Log("定时触发分支")
+		case now := <-ticker.C():
+			t.Logf("定时触发分支 %v", now.Sub(start))
+			ticks++
+		case <-timer.C():
+			t.Log("超时分支")
+			timeouts++
 		default:
-			t.Log("非定时分支")
-			time.Sleep(time.Second)
+			idles++
 		}
-
+		clock.Advance(time.Second)
 	}
+	// 第 10 秒的 tick 在最后一次 Advance 里产生，还没被读取
+	assert.Equal(t, 4, ticks)
+	assert.Equal(t, 1, timeouts)
+	assert.Equal(t, 5, idles)
+	assert.Len(t, ticker.C(), 1)
+	assert.Equal(t, start.Add(10*time.Second), clock.Now())
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Run("按到期顺序触发", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		var order []time.Duration
+		a := clock.NewTimer(3 * time.Second)
+		b := clock.NewTimer(time.Second)
+		clock.Advance(5 * time.Second)
+		for _, tm := range []Timer{a, b} {
+			order = append(order, (<-tm.C()).Sub(start))
+		}
+		assert.Equal(t, []time.Duration{3 * time.Second, time.Second}, order)
+	})
+
+	t.Run("未读取的tick被丢弃", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ticker := clock.NewTicker(time.Second)
+		clock.Advance(5 * time.Second)
+		assert.Equal(t, start.Add(time.Second), <-ticker.C(), "只保留第一个")
+		assert.Len(t, ticker.C(), 0)
+
+		ticker.Reset(2 * time.Second)
+		clock.Advance(3 * time.Second)
+		assert.Equal(t, start.Add(7*time.Second), <-ticker.C())
+		ticker.Stop()
+		clock.Advance(time.Minute)
+		assert.Len(t, ticker.C(), 0)
+	})
+
+	t.Run("Stop和Reset", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		assert.True(t, timer.Stop())
+		assert.False(t, timer.Stop())
+		clock.Advance(time.Second)
+		assert.Len(t, timer.C(), 0)
+
+		assert.False(t, timer.Reset(time.Second))
+		clock.Advance(time.Second)
+		assert.Equal(t, start.Add(2*time.Second), <-timer.C())
+	})
+
+	t.Run("Sleep", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		done := make(chan time.Time)
+		go func() {
+			clock.Sleep(time.Hour)
+			done <- clock.Now()
+		}()
+
+		clock.BlockUntil(1) // 等 Sleep 注册好定时器再推进
+		clock.Advance(59 * time.Minute)
+		select {
+		case <-done:
+			t.Fatal("还没到时间")
+		default:
+		}
+		clock.Advance(time.Minute)
+		assert.Equal(t, start.Add(time.Hour), <-done)
+	})
+
+	t.Run("真实时钟", func(t *testing.T) {
+		timer := RealClock.NewTimer(time.Millisecond)
+		<-timer.C()
+		<-RealClock.After(time.Millisecond)
+		ticker := RealClock.NewTicker(time.Millisecond)
+		<-ticker.C()
+		ticker.Stop()
+		assert.False(t, RealClock.Now().IsZero())
+	})
 }
@@ -0,0 +1,222 @@
+package weightsnippet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/smallnest/weighted"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+Balancer 把 weighted.SW / weighted.RRW 包装成并发安全、感知节点健康的负载均衡器。
+
+- 所有操作在一把锁内完成，weighted.SW/RRW 本身不需要再加锁
+- 动态权重：Add 已存在的节点即更新权重；Remove 下线节点
+- 被动健康检查：调用方通过 Report 上报调用结果，每次失败健康度乘以 FailurePenalty，
+  之后随时间线性恢复，经过 RecoveryTime 从 0 恢复到 1
+- 有效权重 = floor(权重 × 健康度)，为 0 的节点暂时不参与选择；所有节点都为 0 时按原始权重选择，避免全部摘除
+- 有效权重变化时重建内部的 weighted.SW/RRW（平滑状态会重置），不变时不重建
+*/
+
+// Algorithm 加权轮询算法
+type Algorithm int
+
+const (
+	AlgorithmSW  Algorithm = iota // 平滑加权轮询（Nginx）
+	AlgorithmRRW                  // 加权轮询（LVS）
+)
+
+// picker weighted.SW 和 weighted.RRW 的公共方法
+type picker interface {
+	Add(item interface{}, weight int)
+	RemoveAll()
+	Next() interface{}
+}
+
+func newPicker(alg Algorithm) picker {
+	if alg == AlgorithmRRW {
+		return &weighted.RRW{}
+	}
+	return &weighted.SW{}
+}
+
+// BalancerConfig 零值字段使用默认值
+type BalancerConfig struct {
+	Algorithm      Algorithm
+	FailurePenalty float64           // 每次失败健康度乘以它，默认 0.5
+	RecoveryTime   time.Duration     // 健康度从 0 恢复到 1 的时间，默认 30s
+	Clock          timesnippet.Clock // 为空时使用 timesnippet.RealClock
+}
+
+// NodeStatus 节点当前状态
+type NodeStatus struct {
+	Addr            string
+	Weight          int
+	EffectiveWeight int
+	Health          float64
+}
+
+type node struct {
+	addr      string
+	weight    int
+	health    float64
+	updatedAt time.Time // health 的计算时刻
+	effective int
+}
+
+// Balancer 并发安全的加权负载均衡器
+type Balancer struct {
+	cfg BalancerConfig
+
+	mu     sync.Mutex
+	nodes  []*node // 按加入顺序，保证重建后的选择顺序稳定
+	index  map[string]*node
+	picker picker
+	dirty  bool // 节点或有效权重变化，需要重建 picker
+}
+
+// NewBalancer 创建负载均衡器
+func NewBalancer(cfg BalancerConfig) *Balancer {
+	if cfg.FailurePenalty <= 0 || cfg.FailurePenalty >= 1 {
+		cfg.FailurePenalty = 0.5
+	}
+	if cfg.RecoveryTime <= 0 {
+		cfg.RecoveryTime = 30 * time.Second
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	return &Balancer{cfg: cfg, index: make(map[string]*node), picker: newPicker(cfg.Algorithm)}
+}
+
+// Add 加入节点；节点已存在时更新权重，健康度保持不变。weight <= 0 等同于 Remove
+func (b *Balancer) Add(addr string, weight int) {
+	if weight <= 0 {
+		b.Remove(addr)
+		return
+	}
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n, ok := b.index[addr]; ok {
+		n.weight = weight
+	} else {
+		n = &node{addr: addr, weight: weight, health: 1, updatedAt: now}
+		b.nodes = append(b.nodes, n)
+		b.index[addr] = n
+	}
+	b.dirty = true
+}
+
+// Remove 下线节点
+func (b *Balancer) Remove(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.index[addr]; !ok {
+		return
+	}
+	delete(b.index, addr)
+	for i, n := range b.nodes {
+		if n.addr == addr {
+			b.nodes = append(b.nodes[:i], b.nodes[i+1:]...)
+			break
+		}
+	}
+	b.dirty = true
+}
+
+// Update 整体替换节点列表：新增的加入、已有的更新权重（健康度保留）、不在列表中的下线
+func (b *Balancer) Update(nodes []Node) {
+	now := b.cfg.Clock.Now()
+	nodes = validNodes(nodes)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	next := make([]*node, 0, len(nodes))
+	index := make(map[string]*node, len(nodes))
+	for _, n := range nodes {
+		cur, ok := b.index[n.Addr]
+		if !ok {
+			cur = &node{addr: n.Addr, health: 1, updatedAt: now}
+		}
+		cur.weight = n.Weight
+		next = append(next, cur)
+		index[n.Addr] = cur
+	}
+	b.nodes, b.index, b.dirty = next, index, true
+}
+
+// Report 上报一次对 addr 的调用结果，err 非空视为失败
+func (b *Balancer) Report(addr string, err error) {
+	if err == nil {
+		return
+	}
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n, ok := b.index[addr]; ok {
+		b.recoverLocked(n, now)
+		n.health *= b.cfg.FailurePenalty
+	}
+}
+
+// Next 选择一个节点，没有节点时返回 false
+func (b *Balancer) Next() (string, bool) {
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.nodes) == 0 {
+		return "", false
+	}
+	b.refreshLocked(now)
+	return b.picker.Next().(string), true
+}
+
+// Nodes 所有节点的状态
+func (b *Balancer) Nodes() []NodeStatus {
+	now := b.cfg.Clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refreshLocked(now)
+	out := make([]NodeStatus, len(b.nodes))
+	for i, n := range b.nodes {
+		out[i] = NodeStatus{Addr: n.addr, Weight: n.weight, EffectiveWeight: n.effective, Health: n.health}
+	}
+	return out
+}
+
+// recoverLocked 按经过的时间恢复健康度
+func (b *Balancer) recoverLocked(n *node, now time.Time) {
+	if elapsed := now.Sub(n.updatedAt); elapsed > 0 && n.health < 1 {
+		n.health = min(1, n.health+float64(elapsed)/float64(b.cfg.RecoveryTime))
+	}
+	n.updatedAt = now
+}
+
+// refreshLocked 重新计算有效权重，有变化时重建 picker
+func (b *Balancer) refreshLocked(now time.Time) {
+	healthy := false
+	for _, n := range b.nodes {
+		b.recoverLocked(n, now)
+		effective := int(float64(n.weight) * n.health)
+		if effective != n.effective {
+			n.effective, b.dirty = effective, true
+		}
+		healthy = healthy || effective > 0
+	}
+	if !b.dirty {
+		return
+	}
+	b.dirty = false
+	b.picker.RemoveAll()
+	for _, n := range b.nodes {
+		w := n.effective
+		if !healthy {
+			w = n.weight // 全部不健康时按原始权重
+		}
+		if w > 0 {
+			b.picker.Add(n.addr, w)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package weightsnippet
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+// clockStart 测试里 FakeClock 的起始时刻
+var clockStart = time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+
+func nextN(b *Balancer, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i], _ = b.Next()
+	}
+	return out
+}
+
+func countOf(seq []string) map[string]int {
+	counts := make(map[string]int)
+	for _, s := range seq {
+		counts[s]++
+	}
+	return counts
+}
+
+var errCall = errors.New("call failed")
+
+func TestBalancer(t *testing.T) {
+	t.Run("与weighted库的顺序一致", func(t *testing.T) {
+		for _, alg := range []Algorithm{AlgorithmSW, AlgorithmRRW} {
+			b := NewBalancer(BalancerConfig{Algorithm: alg})
+			p := newPicker(alg)
+			for _, n := range []struct {
+				addr   string
+				weight int
+			}{{"a", 5}, {"b", 2}, {"c", 3}} {
+				b.Add(n.addr, n.weight)
+				p.Add(n.addr, n.weight)
+			}
+			for i := 0; i < 20; i++ {
+				got, ok := b.Next()
+				assert.True(t, ok)
+				assert.Equal(t, p.Next(), got)
+			}
+		}
+	})
+
+	t.Run("动态增删节点", func(t *testing.T) {
+		b := NewBalancer(BalancerConfig{})
+		_, ok := b.Next()
+		assert.False(t, ok)
+
+		b.Add("a", 1)
+		b.Add("b", 1)
+		assert.Equal(t, map[string]int{"a": 5, "b": 5}, countOf(nextN(b, 10)))
+
+		b.Add("b", 4) // 更新权重
+		assert.Equal(t, map[string]int{"a": 2, "b": 8}, countOf(nextN(b, 10)))
+
+		b.Remove("a")
+		assert.Equal(t, map[string]int{"b": 10}, countOf(nextN(b, 10)))
+		b.Add("b", 0)
+		_, ok = b.Next()
+		assert.False(t, ok)
+	})
+
+	t.Run("失败降权并随时间恢复", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		b := NewBalancer(BalancerConfig{RecoveryTime: 10 * time.Second, Clock: clock})
+		b.Add("a", 8)
+		b.Add("b", 8)
+
+		b.Report("a", errCall)
+		b.Report("a", nil) // 成功不影响
+		assert.Equal(t, map[string]int{"a": 4, "b": 8}, countOf(nextN(b, 12)))
+
+		b.Report("a", errCall)
+		b.Report("a", errCall)
+		b.Report("a", errCall) // 健康度 1/16，有效权重 0，暂时摘除
+		assert.Equal(t, map[string]int{"b": 12}, countOf(nextN(b, 12)))
+
+		// 5s 恢复 0.5
+		clock.Advance(5 * time.Second)
+		status := b.Nodes()
+		assert.Equal(t, 4, status[0].EffectiveWeight)
+		assert.InDelta(t, 0.5625, status[0].Health, 1e-9)
+
+		clock.Advance(5 * time.Second)
+		assert.Equal(t, map[string]int{"a": 8, "b": 8}, countOf(nextN(b, 16)))
+	})
+
+	t.Run("全部不健康时按原始权重", func(t *testing.T) {
+		b := NewBalancer(BalancerConfig{Clock: timesnippet.NewFakeClock(clockStart)})
+		b.Add("a", 1)
+		b.Add("b", 3)
+		b.Report("a", errCall)
+		b.Report("b", errCall)
+		b.Report("b", errCall)
+		assert.Equal(t, map[string]int{"a": 1, "b": 3}, countOf(nextN(b, 4)))
+	})
+
+	t.Run("并发安全", func(t *testing.T) {
+		b := NewBalancer(BalancerConfig{Algorithm: AlgorithmRRW})
+		b.Add("a", 5)
+		b.Add("b", 2)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					addr, ok := b.Next()
+					if ok && i%100 == 0 {
+						b.Report(addr, errCall)
+					}
+					if g == 0 && i%250 == 0 {
+						b.Add("c", i/250+1)
+						b.Remove("c")
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		assert.Len(t, b.Nodes(), 2)
+	})
+}
@@ -0,0 +1,370 @@
+package weightsnippet
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+SW、RRW 之外的几种负载均衡算法，统一实现 Picker 接口。
+
+| 算法           | 需要 key | 特点                                                                 |
+|----------------|----------|----------------------------------------------------------------------|
+| RingHash       | 是       | 一致性哈希环 + 虚拟节点；节点增删只影响相邻区间的 key，分布均匀度取决于虚拟节点数 |
+| Maglev         | 是       | 查找表（大小为质数 M）按各节点的排列轮流填充，分布几乎完全均匀，查找 O(1)；增删节点的迁移略多于理论最小值 |
+| P2C            | 否       | 随机取两个节点，选代价（EWMA 延迟 × (在途请求+1) / 权重）小的，对慢节点自适应 |
+| LeastConn      | 否       | 在途请求数 / 权重最小的节点，并列时轮流                               |
+| RandomWeighted | 否       | 按权重随机，前缀和 + 二分查找                                         |
+
+节点列表变化时重新构建 Picker；P2C、LeastConn 的统计依赖 Pick 返回的 done 回调。
+*/
+
+// ErrNoNodes 没有可用节点
+var ErrNoNodes = errors.New("weightsnippet: no nodes")
+
+// Node 节点及其权重
+type Node struct {
+	Addr   string
+	Weight int
+}
+
+// Picker 负载均衡选择器，实现都是并发安全的
+type Picker interface {
+	// Pick 选择一个节点。key 只对哈希类算法有意义；done 必须在调用结束后调用一次
+	Pick(key string) (addr string, done func(), err error)
+}
+
+func noop() {}
+
+// Pick 让 Balancer 也实现 Picker，key 被忽略
+func (b *Balancer) Pick(string) (string, func(), error) {
+	addr, ok := b.Next()
+	if !ok {
+		return "", nil, ErrNoNodes
+	}
+	return addr, noop, nil
+}
+
+// validNodes 去掉权重不为正的节点
+func validNodes(nodes []Node) []Node {
+	out := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Weight > 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// hash64 FNV-1a 再经过 splitmix64 的终结函数打散，相似的字符串也能得到分散的哈希值
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// RingHash 一致性哈希环
+type RingHash struct {
+	hashes []uint64 // 升序
+	owners []string
+}
+
+// NewRingHash 每单位权重 replicas 个虚拟节点，replicas <= 0 时默认 160
+func NewRingHash(nodes []Node, replicas int) *RingHash {
+	if replicas <= 0 {
+		replicas = 160
+	}
+	type point struct {
+		hash  uint64
+		owner string
+	}
+	var points []point
+	for _, n := range validNodes(nodes) {
+		for i := 0; i < n.Weight*replicas; i++ {
+			points = append(points, point{hash64(n.Addr + "#" + strconv.Itoa(i)), n.Addr})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	r := &RingHash{hashes: make([]uint64, len(points)), owners: make([]string, len(points))}
+	for i, p := range points {
+		r.hashes[i], r.owners[i] = p.hash, p.owner
+	}
+	return r
+}
+
+// Pick 顺时针找到第一个虚拟节点
+func (r *RingHash) Pick(key string) (string, func(), error) {
+	if len(r.hashes) == 0 {
+		return "", nil, ErrNoNodes
+	}
+	h := hash64(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[i], noop, nil
+}
+
+// Maglev Google Maglev 一致性哈希
+type Maglev struct {
+	table []string
+}
+
+// DefaultMaglevTableSize 查找表大小，应为质数且远大于节点数 × 权重
+const DefaultMaglevTableSize = 65537
+
+// NewMaglev 构建查找表，tableSize <= 0 时使用 DefaultMaglevTableSize，不是质数时向上取到下一个质数。
+// 每一轮中权重为 w 的节点填充 w 个位置，因此各节点占的位置数与权重成正比
+func NewMaglev(nodes []Node, tableSize int) *Maglev {
+	if tableSize <= 0 {
+		tableSize = DefaultMaglevTableSize
+	}
+	// skip ∈ [1, M) 与 M 互质，节点的排列才能遍历所有位置，否则填表会死循环；M=1 时 M-1 为 0
+	tableSize = nextPrime(tableSize)
+	nodes = validNodes(nodes)
+	m := &Maglev{}
+	if len(nodes) == 0 {
+		return m
+	}
+
+	M := uint64(tableSize)
+	offsets, skips, next := make([]uint64, len(nodes)), make([]uint64, len(nodes)), make([]uint64, len(nodes))
+	for i, n := range nodes {
+		offsets[i] = hash64("offset:"+n.Addr) % M
+		skips[i] = hash64("skip:"+n.Addr)%(M-1) + 1
+	}
+
+	m.table = make([]string, tableSize)
+	filled := make([]bool, tableSize)
+	for count := 0; ; {
+		for i, n := range nodes {
+			for w := 0; w < n.Weight; w++ {
+				// 沿节点自己的排列找到下一个空位
+				var slot uint64
+				for {
+					slot = (offsets[i] + next[i]*skips[i]) % M
+					next[i]++
+					if !filled[slot] {
+						break
+					}
+				}
+				m.table[slot], filled[slot] = n.Addr, true
+				if count++; count == tableSize {
+					return m
+				}
+			}
+		}
+	}
+}
+
+// nextPrime >= n 的最小质数
+func nextPrime(n int) int {
+	if n <= 2 {
+		return 2
+	}
+	for n |= 1; ; n += 2 {
+		prime := true
+		for d := 3; d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+func (m *Maglev) Pick(key string) (string, func(), error) {
+	if len(m.table) == 0 {
+		return "", nil, ErrNoNodes
+	}
+	return m.table[hash64(key)%uint64(len(m.table))], noop, nil
+}
+
+// lockedRand *rand.Rand 非并发安全
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(src rand.Source) *lockedRand {
+	if src == nil {
+		src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	return &lockedRand{r: rand.New(src)}
+}
+
+func (l *lockedRand) IntN(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.IntN(n)
+}
+
+// P2CConfig 零值字段使用默认值
+type P2CConfig struct {
+	Decay  time.Duration     // EWMA 延迟的时间常数，默认 10s
+	Source rand.Source       // 随机源，测试时传入固定种子
+	Clock  timesnippet.Clock // 为空时使用 timesnippet.RealClock
+}
+
+type p2cNode struct {
+	Node
+	inFlight int
+	latency  float64 // EWMA 延迟，纳秒；0 表示还没有样本
+	updated  time.Time
+}
+
+// P2C power of two choices
+type P2C struct {
+	cfg  P2CConfig
+	rand *lockedRand
+
+	mu    sync.Mutex
+	nodes []*p2cNode
+}
+
+// NewP2C 创建 P2C 选择器
+func NewP2C(nodes []Node, cfg P2CConfig) *P2C {
+	if cfg.Decay <= 0 {
+		cfg.Decay = 10 * time.Second
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	p := &P2C{cfg: cfg, rand: newLockedRand(cfg.Source)}
+	for _, n := range validNodes(nodes) {
+		p.nodes = append(p.nodes, &p2cNode{Node: n})
+	}
+	return p
+}
+
+// cost 没有延迟样本的节点代价按在途请求数算，保证新节点能被选中
+func (n *p2cNode) cost() float64 {
+	return math.Max(n.latency, 1) * float64(n.inFlight+1) / float64(n.Weight)
+}
+
+func (p *P2C) Pick(string) (string, func(), error) {
+	if len(p.nodes) == 0 {
+		return "", nil, ErrNoNodes
+	}
+	i := p.rand.IntN(len(p.nodes))
+	j := i
+	if len(p.nodes) > 1 {
+		j = (i + 1 + p.rand.IntN(len(p.nodes)-1)) % len(p.nodes)
+	}
+
+	start := p.cfg.Clock.Now()
+	p.mu.Lock()
+	chosen := p.nodes[i]
+	if p.nodes[j].cost() < chosen.cost() {
+		chosen = p.nodes[j]
+	}
+	chosen.inFlight++
+	p.mu.Unlock()
+
+	var once sync.Once
+	return chosen.Addr, func() {
+		once.Do(func() { p.observe(chosen, start) })
+	}, nil
+}
+
+func (p *P2C) observe(n *p2cNode, start time.Time) {
+	now := p.cfg.Clock.Now()
+	rtt := float64(now.Sub(start))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n.inFlight--
+	if n.latency == 0 {
+		n.latency = rtt
+	} else {
+		// 按距上次更新的时间衰减，长时间没有样本的旧值权重更低
+		w := math.Exp(-float64(now.Sub(n.updated)) / float64(p.cfg.Decay))
+		n.latency = n.latency*w + rtt*(1-w)
+	}
+	n.updated = now
+}
+
+// LeastConn 最少在途请求
+type LeastConn struct {
+	mu       sync.Mutex
+	nodes    []Node
+	inFlight []int
+	next     int // 并列时从这里开始找，实现轮流
+}
+
+// NewLeastConn 创建最少连接选择器
+func NewLeastConn(nodes []Node) *LeastConn {
+	nodes = validNodes(nodes)
+	return &LeastConn{nodes: nodes, inFlight: make([]int, len(nodes))}
+}
+
+func (l *LeastConn) Pick(string) (string, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.nodes) == 0 {
+		return "", nil, ErrNoNodes
+	}
+	best := -1
+	for k := 0; k < len(l.nodes); k++ {
+		i := (l.next + k) % len(l.nodes)
+		// inFlight[i]/weight[i] < inFlight[best]/weight[best]，交叉相乘避免浮点
+		if best < 0 || l.inFlight[i]*l.nodes[best].Weight < l.inFlight[best]*l.nodes[i].Weight {
+			best = i
+		}
+	}
+	l.next = (best + 1) % len(l.nodes)
+	l.inFlight[best]++
+
+	var once sync.Once
+	return l.nodes[best].Addr, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight[best]--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// RandomWeighted 按权重随机
+type RandomWeighted struct {
+	nodes  []Node
+	prefix []int // 权重前缀和
+	rand   *lockedRand
+}
+
+// NewRandomWeighted src 为空时使用随机种子
+func NewRandomWeighted(nodes []Node, src rand.Source) *RandomWeighted {
+	r := &RandomWeighted{nodes: validNodes(nodes), rand: newLockedRand(src)}
+	total := 0
+	for _, n := range r.nodes {
+		total += n.Weight
+		r.prefix = append(r.prefix, total)
+	}
+	return r
+}
+
+func (r *RandomWeighted) Pick(string) (string, func(), error) {
+	if len(r.nodes) == 0 {
+		return "", nil, ErrNoNodes
+	}
+	x := r.rand.IntN(r.prefix[len(r.prefix)-1])
+	i := sort.SearchInts(r.prefix, x+1)
+	return r.nodes[i].Addr, noop, nil
+}
@@ -0,0 +1,240 @@
+package weightsnippet
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+func makeNodes(weights ...int) []Node {
+	nodes := make([]Node, len(weights))
+	for i, w := range weights {
+		nodes[i] = Node{Addr: fmt.Sprintf("10.0.0.%d:80", i+1), Weight: w}
+	}
+	return nodes
+}
+
+func keys(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("user-%d", i)
+	}
+	return out
+}
+
+// distribution 每个 key 取一次（done 立即调用），返回各节点命中数
+func distribution(t *testing.T, p Picker, ks []string) map[string]int {
+	counts := make(map[string]int)
+	for _, k := range ks {
+		addr, done, err := p.Pick(k)
+		assert.NoError(t, err)
+		done()
+		counts[addr]++
+	}
+	return counts
+}
+
+// skew 各节点实际占比与期望占比（按权重）之比的最大值，1 表示完全按权重分配
+func skew(nodes []Node, counts map[string]int) float64 {
+	total, weights := 0, 0
+	for _, n := range nodes {
+		total += counts[n.Addr]
+		weights += n.Weight
+	}
+	worst := 0.0
+	for _, n := range nodes {
+		expected := float64(total) * float64(n.Weight) / float64(weights)
+		worst = max(worst, float64(counts[n.Addr])/expected)
+	}
+	return worst
+}
+
+// churn 节点变化前后映射到不同节点的 key 占比
+func churn(before, after Picker, ks []string) float64 {
+	moved := 0
+	for _, k := range ks {
+		a, _, _ := before.Pick(k)
+		b, _, _ := after.Pick(k)
+		if a != b {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(ks))
+}
+
+func TestPickerDistribution(t *testing.T) {
+	ks := keys(100000)
+	pickers := []struct {
+		name    string
+		build   func(nodes []Node) Picker
+		maxSkew float64
+	}{
+		{"RingHash", func(nodes []Node) Picker { return NewRingHash(nodes, 0) }, 1.25},
+		{"Maglev", func(nodes []Node) Picker { return NewMaglev(nodes, 0) }, 1.03},
+		{"RandomWeighted", func(nodes []Node) Picker { return NewRandomWeighted(nodes, rand.NewPCG(1, 2)) }, 1.03},
+		{"LeastConn", func(nodes []Node) Picker { return NewLeastConn(nodes) }, 1.0},
+		{"P2C", func(nodes []Node) Picker {
+			// 假时钟下延迟都是 0，各节点代价相同，退化为均匀随机
+			return NewP2C(nodes, P2CConfig{Source: rand.NewPCG(1, 2), Clock: timesnippet.NewFakeClock(clockStart)})
+		}, 1.03},
+		{"SW", func(nodes []Node) Picker {
+			b := NewBalancer(BalancerConfig{})
+			for _, n := range nodes {
+				b.Add(n.Addr, n.Weight)
+			}
+			return b
+		}, 1.0},
+	}
+
+	for _, p := range pickers {
+		t.Run(p.name+"/等权重", func(t *testing.T) {
+			nodes := makeNodes(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+			s := skew(nodes, distribution(t, p.build(nodes), ks))
+			t.Logf("skew = %.4f", s)
+			assert.LessOrEqual(t, s, p.maxSkew+1e-9)
+		})
+	}
+
+	// LeastConn、P2C 在 done 立即调用时不体现权重（在途请求都是 0），只测按权重分配的算法
+	for _, p := range pickers {
+		if p.name == "LeastConn" || p.name == "P2C" {
+			continue
+		}
+		t.Run(p.name+"/按权重", func(t *testing.T) {
+			nodes := makeNodes(1, 2, 3, 4)
+			s := skew(nodes, distribution(t, p.build(nodes), ks))
+			t.Logf("skew = %.4f", s)
+			assert.LessOrEqual(t, s, p.maxSkew+1e-9)
+		})
+	}
+}
+
+func TestPickerChurn(t *testing.T) {
+	ks := keys(100000)
+	nodes := makeNodes(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	removed := nodes[:9]
+	added := append(makeNodes(1, 1, 1, 1, 1, 1, 1, 1, 1, 1), Node{Addr: "10.0.0.11:80", Weight: 1})
+
+	t.Run("RingHash", func(t *testing.T) {
+		before := NewRingHash(nodes, 0)
+		// 删除节点：只有原来落在被删节点上的 key 迁移
+		c := churn(before, NewRingHash(removed, 0), ks)
+		t.Logf("删除 1/10 节点迁移 %.4f", c)
+		assert.InDelta(t, 0.1, c, 0.02)
+		after, stayed := NewRingHash(removed, 0), 0
+		for _, k := range ks {
+			a, _, _ := before.Pick(k)
+			b, _, _ := after.Pick(k)
+			if a != nodes[9].Addr && a != b {
+				stayed++
+			}
+		}
+		assert.Equal(t, 0, stayed, "其余节点上的 key 不受影响")
+		c = churn(before, NewRingHash(added, 0), ks)
+		t.Logf("增加 1 个节点迁移 %.4f", c)
+		assert.InDelta(t, 1.0/11, c, 0.02)
+	})
+
+	t.Run("Maglev", func(t *testing.T) {
+		before := NewMaglev(nodes, 0)
+		c := churn(before, NewMaglev(removed, 0), ks)
+		t.Logf("删除 1/10 节点迁移 %.4f", c)
+		assert.GreaterOrEqual(t, c, 0.09)
+		assert.Less(t, c, 0.15)
+		c = churn(before, NewMaglev(added, 0), ks)
+		t.Logf("增加 1 个节点迁移 %.4f", c)
+		assert.Less(t, c, 0.15)
+	})
+
+	t.Run("RandomWeighted没有亲和性", func(t *testing.T) {
+		// 对比：不按 key 选择的算法，节点不变也会“迁移”大部分 key
+		c := churn(NewRandomWeighted(nodes, rand.NewPCG(1, 2)), NewRandomWeighted(nodes, rand.NewPCG(3, 4)), ks)
+		assert.Greater(t, c, 0.8)
+	})
+}
+
+func TestP2C(t *testing.T) {
+	t.Run("避开慢节点", func(t *testing.T) {
+		clock := timesnippet.NewFakeClock(clockStart)
+		nodes := makeNodes(1, 1, 1, 1)
+		p := NewP2C(nodes, P2CConfig{Source: rand.NewPCG(1, 2), Clock: clock})
+		slow := nodes[0].Addr
+
+		counts := make(map[string]int)
+		for i := 0; i < 10000; i++ {
+			addr, done, _ := p.Pick("")
+			latency := 10 * time.Millisecond
+			if addr == slow {
+				latency = 100 * time.Millisecond
+			}
+			clock.Advance(latency)
+			done()
+			counts[addr]++
+		}
+		t.Logf("%v", counts)
+		// 两个候选总是不同的节点，慢节点有了延迟样本之后就不会再被选中
+		assert.Less(t, counts[slow], 100)
+	})
+
+	t.Run("在途请求多的节点代价高", func(t *testing.T) {
+		nodes := makeNodes(1, 1)
+		p := NewP2C(nodes, P2CConfig{Source: rand.NewPCG(1, 2), Clock: timesnippet.NewFakeClock(clockStart)})
+		first, _, _ := p.Pick("")
+		second, _, _ := p.Pick("")
+		assert.NotEqual(t, first, second)
+	})
+}
+
+func TestLeastConn(t *testing.T) {
+	nodes := makeNodes(1, 2)
+	l := NewLeastConn(nodes)
+
+	// 不释放连接时按权重分摊在途请求
+	counts := make(map[string]int)
+	dones := make(map[string][]func())
+	for i := 0; i < 30; i++ {
+		addr, done, _ := l.Pick("")
+		counts[addr]++
+		dones[addr] = append(dones[addr], done)
+	}
+	assert.Equal(t, map[string]int{nodes[0].Addr: 10, nodes[1].Addr: 20}, counts)
+
+	// 释放第一个节点上的 5 个连接后，接下来的请求都去它那里，直到比例重新持平
+	for _, done := range dones[nodes[0].Addr][:5] {
+		done()
+		done() // 重复调用无效
+	}
+	for i := 0; i < 5; i++ {
+		addr, _, _ := l.Pick("")
+		assert.Equal(t, nodes[0].Addr, addr)
+	}
+}
+
+func TestMaglevTableSize(t *testing.T) {
+	nodes := []Node{{"10.0.0.1:80", 3}, {"10.0.0.2:80", 1}}
+	for _, c := range []struct{ size, want int }{
+		{0, DefaultMaglevTableSize}, {1, 2}, {2, 2}, {100, 101}, {101, 101}, {65536, 65537},
+	} {
+		t.Run(fmt.Sprint(c.size), func(t *testing.T) {
+			// 非质数大小以前会在填表时死循环，1 会对 0 取模
+			m := NewMaglev(nodes, c.size)
+			assert.Len(t, m.table, c.want)
+			assert.NotContains(t, m.table, "", "查找表填满")
+		})
+	}
+}
+
+func TestPickerEmpty(t *testing.T) {
+	for _, p := range []Picker{
+		NewRingHash(nil, 0), NewMaglev(nil, 0), NewP2C(nil, P2CConfig{}),
+		NewLeastConn(nil), NewRandomWeighted([]Node{{Addr: "x", Weight: 0}}, nil), NewBalancer(BalancerConfig{}),
+	} {
+		_, _, err := p.Pick("k")
+		assert.ErrorIs(t, err, ErrNoNodes)
+	}
+}
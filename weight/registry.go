@@ -0,0 +1,266 @@
+package weightsnippet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gopkg.in/yaml.v3"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+客户端服务发现：Resolver 负责从注册中心取当前节点列表，Watch 定期拉取并把变化推给负载均衡器。
+
+	r := &DNSSRV{Service: "http", Proto: "tcp", Name: "api.example.com"}
+	go Watch(ctx, r, balancer, WatchConfig{Interval: 10 * time.Second, Debounce: time.Second, MinNodes: 2})
+
+三种后端：
+- StaticFile：JSON/YAML 文件 {"nodes": [{"addr": "10.0.0.1:80", "weight": 5}]}
+- DNSSRV：SRV 记录，只取优先级最高（Priority 最小）的一组，SRV 权重为 0 时按 1 处理
+- RedisRegistry：每个实例一个带 TTL 的 key（registry:<service>:<addr> = 权重），实例定期 Register 续期，
+  进程退出或失联后 key 过期自动下线
+
+Watch 的两个保护：
+- Debounce：列表变化后要稳定 Debounce 时间才生效，滚动发布时节点逐个上下线不会让负载均衡器频繁重建
+- MinNodes：新列表的节点数少于 MinNodes 时拒绝更新并报错，继续使用旧列表，
+  防止注册中心故障（返回空列表）把所有流量打到剩下的少数节点上
+*/
+
+// Resolver 获取当前的节点列表
+type Resolver interface {
+	Resolve(ctx context.Context) ([]Node, error)
+}
+
+// NodeUpdater 接收节点列表的负载均衡器，Balancer、ScheduledPicker 都实现了它
+type NodeUpdater interface {
+	Update(nodes []Node)
+}
+
+// StaticFile 从 JSON/YAML 文件读取节点
+type StaticFile struct {
+	Path string
+}
+
+type nodeFile struct {
+	Nodes []struct {
+		Addr   string `json:"addr" yaml:"addr"`
+		Weight int    `json:"weight" yaml:"weight"`
+	} `json:"nodes" yaml:"nodes"`
+}
+
+func (f *StaticFile) Resolve(context.Context) ([]Node, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	// YAML 是 JSON 的超集，两种格式都用 yaml 解析
+	var file nodeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(f.Path), err)
+	}
+	nodes := make([]Node, 0, len(file.Nodes))
+	for _, n := range file.Nodes {
+		if n.Addr == "" {
+			return nil, fmt.Errorf("parse %s: node without addr", filepath.Base(f.Path))
+		}
+		nodes = append(nodes, Node{Addr: n.Addr, Weight: n.Weight})
+	}
+	return nodes, nil
+}
+
+// DNSSRV 通过 SRV 记录发现节点
+type DNSSRV struct {
+	Service, Proto, Name string
+	Resolver             *net.Resolver // 为空时使用 net.DefaultResolver
+}
+
+func (d *DNSSRV) Resolve(ctx context.Context) ([]Node, error) {
+	r := d.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	_, srvs, err := r.LookupSRV(ctx, d.Service, d.Proto, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	// LookupSRV 已按优先级排序
+	var nodes []Node
+	for _, srv := range srvs {
+		if srv.Priority != srvs[0].Priority {
+			break
+		}
+		nodes = append(nodes, Node{
+			Addr:   net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))),
+			Weight: max(int(srv.Weight), 1),
+		})
+	}
+	return nodes, nil
+}
+
+// RedisRegistry 基于 Redis 的注册中心
+type RedisRegistry struct {
+	Client  redis.Cmdable
+	Service string
+	Prefix  string // 默认 "registry"
+}
+
+func (r *RedisRegistry) keyPrefix() string {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = "registry"
+	}
+	return prefix + ":" + r.Service + ":"
+}
+
+// Register 注册或续期一个实例，ttl 内不续期即下线
+func (r *RedisRegistry) Register(ctx context.Context, n Node, ttl time.Duration) error {
+	return r.Client.Set(ctx, r.keyPrefix()+n.Addr, n.Weight, ttl).Err()
+}
+
+// Deregister 主动下线
+func (r *RedisRegistry) Deregister(ctx context.Context, addr string) error {
+	return r.Client.Del(ctx, r.keyPrefix()+addr).Err()
+}
+
+func (r *RedisRegistry) Resolve(ctx context.Context) ([]Node, error) {
+	prefix := r.keyPrefix()
+	var keys []string
+	iter := r.Client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := r.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]Node, 0, len(keys))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue // SCAN 和 MGET 之间过期了
+		}
+		weight, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad weight for %s: %w", keys[i], err)
+		}
+		nodes = append(nodes, Node{Addr: strings.TrimPrefix(keys[i], prefix), Weight: weight})
+	}
+	return nodes, nil
+}
+
+// ErrTooFewNodes 新的节点列表少于 MinNodes，更新被拒绝
+var ErrTooFewNodes = errors.New("weightsnippet: too few nodes, keeping previous list")
+
+// WatchConfig 零值字段使用默认值
+type WatchConfig struct {
+	Interval time.Duration      // 拉取间隔，默认 10s
+	Debounce time.Duration      // 变化稳定多久后生效，默认 1s；首次拉取的结果立即生效
+	MinNodes int                // 少于它时拒绝更新，默认 1
+	OnUpdate func(nodes []Node) // 应用新列表后回调
+	OnError  func(err error)    // 拉取失败或被 MinNodes 拒绝时回调
+	Clock    timesnippet.Clock  // 为空时使用 timesnippet.RealClock
+}
+
+// normalizeNodes 去掉无效节点并按地址排序，便于比较
+func normalizeNodes(nodes []Node) []Node {
+	nodes = validNodes(nodes)
+	slices.SortFunc(nodes, func(a, b Node) int { return strings.Compare(a.Addr, b.Addr) })
+	return nodes
+}
+
+// Watch 定期从 r 拉取节点列表并更新 target，直到 ctx 结束
+func Watch(ctx context.Context, r Resolver, target NodeUpdater, cfg WatchConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = time.Second
+	}
+	if cfg.MinNodes <= 0 {
+		cfg.MinNodes = 1
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = timesnippet.RealClock
+	}
+	report := func(err error) {
+		if cfg.OnError != nil {
+			cfg.OnError(err)
+		}
+	}
+	var applied, rejected []Node
+	hasRejected := false
+	apply := func(nodes []Node) {
+		if len(nodes) < cfg.MinNodes {
+			rejected, hasRejected = nodes, true
+			report(fmt.Errorf("%w: got %d, want at least %d", ErrTooFewNodes, len(nodes), cfg.MinNodes))
+			return
+		}
+		target.Update(nodes)
+		applied, hasRejected = nodes, false
+		if cfg.OnUpdate != nil {
+			cfg.OnUpdate(nodes)
+		}
+	}
+
+	debounce := cfg.Clock.NewTimer(cfg.Debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	var pending []Node
+	first := true
+	resolve := func() {
+		nodes, err := r.Resolve(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				report(err)
+			}
+			return
+		}
+		nodes = normalizeNodes(nodes)
+		switch {
+		case first:
+			first = false
+			apply(nodes)
+		case slices.Equal(nodes, applied), hasRejected && slices.Equal(nodes, rejected):
+			// 没变、变化又恢复了、或者是已经拒绝过的列表：取消待生效的更新
+			pending = nil
+			debounce.Stop()
+		case pending == nil || !slices.Equal(nodes, pending):
+			// 新的变化，重新开始计时
+			pending = nodes
+			debounce.Reset(cfg.Debounce)
+		}
+	}
+
+	ticker := cfg.Clock.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	resolve()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			resolve()
+		case <-debounce.C():
+			apply(pending)
+			pending = nil
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package weightsnippet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+func TestStaticFile(t *testing.T) {
+	dir := t.TempDir()
+	want := []Node{{"10.0.0.1:80", 5}, {"10.0.0.2:80", 1}}
+
+	t.Run("JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "nodes.json")
+		os.WriteFile(path, []byte(`{"nodes": [{"addr": "10.0.0.1:80", "weight": 5}, {"addr": "10.0.0.2:80", "weight": 1}]}`), 0o644)
+		nodes, err := (&StaticFile{Path: path}).Resolve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, want, nodes)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		path := filepath.Join(dir, "nodes.yaml")
+		os.WriteFile(path, []byte("nodes:\n  - addr: 10.0.0.1:80\n    weight: 5\n  - addr: 10.0.0.2:80\n    weight: 1\n"), 0o644)
+		nodes, err := (&StaticFile{Path: path}).Resolve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, want, nodes)
+	})
+
+	t.Run("缺少地址报错", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.yaml")
+		os.WriteFile(path, []byte("nodes:\n  - weight: 5\n"), 0o644)
+		_, err := (&StaticFile{Path: path}).Resolve(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+// serveSRV 在本地 UDP 端口上启动一个只应答 SRV 查询的 DNS 服务
+func serveSRV(t *testing.T, srvs []dnsmessage.SRVResource) *net.Resolver {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen udp: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			header, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true})
+			b.EnableCompression()
+			b.StartQuestions()
+			b.Question(q)
+			b.StartAnswers()
+			if q.Type == dnsmessage.TypeSRV {
+				for _, srv := range srvs {
+					b.SRVResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60}, srv)
+				}
+			}
+			msg, err := b.Finish()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(msg, addr)
+		}
+	}()
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+}
+
+func TestDNSSRV(t *testing.T) {
+	resolver := serveSRV(t, []dnsmessage.SRVResource{
+		{Priority: 10, Weight: 5, Port: 8080, Target: dnsmessage.MustNewName("a.example.com.")},
+		{Priority: 10, Weight: 0, Port: 8081, Target: dnsmessage.MustNewName("b.example.com.")},
+		{Priority: 20, Weight: 9, Port: 8080, Target: dnsmessage.MustNewName("backup.example.com.")},
+	})
+
+	r := &DNSSRV{Service: "http", Proto: "tcp", Name: "example.com", Resolver: resolver}
+	nodes, err := r.Resolve(context.Background())
+	assert.NoError(t, err)
+	// 只保留 Priority 最小的一组，权重 0 按 1 处理
+	assert.ElementsMatch(t, []Node{{"a.example.com:8080", 5}, {"b.example.com:8081", 1}}, normalizeNodes(nodes))
+}
+
+func TestRedisRegistry(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr, Password: os.Getenv("REDIS_PASSWORD"), DialTimeout: time.Second})
+	defer rdb.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis server not available: %v", err)
+	}
+
+	reg := &RedisRegistry{Client: rdb, Service: "weight-test-" + time.Now().Format("150405.000000")}
+	assert.NoError(t, reg.Register(ctx, Node{"10.0.0.1:80", 3}, time.Minute))
+	assert.NoError(t, reg.Register(ctx, Node{"10.0.0.2:80", 1}, 200*time.Millisecond))
+	defer reg.Deregister(ctx, "10.0.0.1:80")
+
+	nodes, err := reg.Resolve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Node{{"10.0.0.1:80", 3}, {"10.0.0.2:80", 1}}, normalizeNodes(nodes))
+
+	// 未续期的实例过期下线
+	assert.Eventually(t, func() bool {
+		nodes, err := reg.Resolve(ctx)
+		return err == nil && len(nodes) == 1 && nodes[0].Addr == "10.0.0.1:80"
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+// fakeResolver 返回可随时修改的节点列表；calls 不为空时每次拉取先在上面发一个信号
+type fakeResolver struct {
+	calls chan struct{}
+
+	mu    sync.Mutex
+	nodes []Node
+	err   error
+}
+
+func (f *fakeResolver) set(nodes []Node, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nodes, f.err = nodes, err
+}
+
+func (f *fakeResolver) Resolve(ctx context.Context) ([]Node, error) {
+	if f.calls != nil {
+		select {
+		case f.calls <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Node(nil), f.nodes...), f.err
+}
+
+// recorder 把 Watch 的回调转发到 channel
+type recorder struct {
+	updates chan []Node
+	errs    chan error
+}
+
+func newRecorder() *recorder {
+	return &recorder{updates: make(chan []Node, 16), errs: make(chan error, 16)}
+}
+
+func (r *recorder) config(clock timesnippet.Clock, interval, debounce time.Duration, minNodes int) WatchConfig {
+	return WatchConfig{
+		Interval: interval,
+		Debounce: debounce,
+		MinNodes: minNodes,
+		OnUpdate: func(nodes []Node) { r.updates <- nodes },
+		OnError:  func(err error) { r.errs <- err },
+		Clock:    clock,
+	}
+}
+
+// assertQuiet 没有未读的回调
+func (r *recorder) assertQuiet(t *testing.T) {
+	t.Helper()
+	assert.Empty(t, r.updates)
+	assert.Empty(t, r.errs)
+}
+
+// recv 等 ch 上的下一个值，墙上时间 1s 只是防止测试挂死
+func recv[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+		var zero T
+		return zero
+	}
+}
+
+// watchHarness 用 FakeClock 驱动 Watch：拉取间隔 10s，每轮拉取都要等测试收到 calls 才继续
+type watchHarness struct {
+	t     *testing.T
+	clock *timesnippet.FakeClock
+	res   *fakeResolver
+	rec   *recorder
+}
+
+const watchInterval = 10 * time.Second
+
+func startWatch(t *testing.T, nodes []Node, target NodeUpdater, debounce time.Duration, minNodes int) *watchHarness {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	h := &watchHarness{
+		t:     t,
+		clock: timesnippet.NewFakeClock(clockStart),
+		res:   &fakeResolver{calls: make(chan struct{}), nodes: nodes},
+		rec:   newRecorder(),
+	}
+	go Watch(ctx, h.res, target, h.rec.config(h.clock, watchInterval, debounce, minNodes))
+	recv(t, h.res.calls) // 首次拉取，此时 ticker 已经创建
+	return h
+}
+
+// tick 推进一个拉取间隔并等 Watch 开始下一轮拉取；
+// Watch 是单个 goroutine，能开始下一轮说明上一轮的结果已经处理完
+func (h *watchHarness) tick() {
+	h.t.Helper()
+	h.clock.Advance(watchInterval)
+	recv(h.t, h.res.calls)
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("首次立即生效，变化防抖合并", func(t *testing.T) {
+		b := NewScheduledPicker(nil)
+		h := startWatch(t, makeNodes(1, 1), b, 25*time.Second, 1)
+		assert.Len(t, recv(t, h.rec.updates), 2)
+		assert.Len(t, b.Nodes(), 2)
+
+		// 滚动发布：节点逐个变化，间隔小于 Debounce
+		h.res.set(makeNodes(1, 1, 1), nil)
+		h.tick() // 10s：开始计时，35s 生效
+		h.res.set(makeNodes(1, 1, 1, 1), nil)
+		h.tick() // 20s：又变了，重新计时，45s 生效
+		h.tick() // 30s：中间状态的 35s 已经作废
+		h.tick() // 40s
+		h.rec.assertQuiet(t)
+
+		h.clock.Advance(5 * time.Second)
+		assert.Len(t, recv(t, h.rec.updates), 4, "中间状态 3 个节点不应生效")
+		assert.Len(t, b.Nodes(), 4)
+		h.tick()
+		h.rec.assertQuiet(t)
+	})
+
+	t.Run("变化又恢复时取消更新", func(t *testing.T) {
+		h := startWatch(t, makeNodes(1, 1), NewScheduledPicker(nil), 25*time.Second, 1)
+		recv(t, h.rec.updates)
+
+		h.res.set(makeNodes(1), nil)
+		h.tick() // 10s：开始计时，35s 生效
+		h.res.set(makeNodes(1, 1), nil)
+		h.tick() // 20s：恢复原状，取消
+		for i := 0; i < 4; i++ {
+			h.tick()
+		}
+		h.rec.assertQuiet(t)
+	})
+
+	t.Run("节点过少时拒绝并保留旧列表", func(t *testing.T) {
+		b := NewBalancer(BalancerConfig{})
+		h := startWatch(t, makeNodes(2, 1, 1), b, 5*time.Second, 2)
+		recv(t, h.rec.updates)
+
+		h.res.set(nil, nil) // 注册中心返回空列表
+		h.tick()
+		h.clock.BlockUntil(2) // ticker + 防抖定时器
+		h.clock.Advance(5 * time.Second)
+		assert.ErrorIs(t, recv(t, h.rec.errs), ErrTooFewNodes)
+
+		for i := 0; i < 3; i++ {
+			h.tick()
+		}
+		h.rec.assertQuiet(t) // 同一个被拒绝的列表只报一次
+		assert.Len(t, b.Nodes(), 3)
+	})
+
+	t.Run("拉取失败不影响当前列表", func(t *testing.T) {
+		b := NewBalancer(BalancerConfig{})
+		h := startWatch(t, makeNodes(1, 1), b, 5*time.Second, 1)
+		recv(t, h.rec.updates)
+
+		boom := errors.New("registry down")
+		h.res.set(nil, boom)
+		h.tick()
+		assert.ErrorIs(t, recv(t, h.rec.errs), boom)
+		assert.Len(t, b.Nodes(), 2)
+	})
+}
+
+// Balancer.Update 保留已有节点的健康度
+func TestBalancerUpdate(t *testing.T) {
+	b := NewBalancer(BalancerConfig{Clock: timesnippet.NewFakeClock(clockStart)})
+	b.Update(makeNodes(4, 4))
+	b.Report("10.0.0.1:80", errors.New("fail"))
+
+	b.Update(makeNodes(8, 4, 2))
+	status := b.Nodes()
+	assert.Len(t, status, 3)
+	assert.Equal(t, 8, status[0].Weight)
+	assert.Equal(t, 0.5, status[0].Health)
+	assert.Equal(t, 1.0, status[2].Health)
+
+	b.Update(makeNodes(8)[:1])
+	assert.Len(t, b.Nodes(), 1)
+}
@@ -0,0 +1,188 @@
+package weightsnippet
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+预计算调度表的无锁加权选择器。
+
+平滑加权轮询的选择序列以 Σ权重/gcd 为周期重复，所以可以在权重变化时一次性算出一个周期的序列（不可变的调度表），
+之后每次选择只需要一次原子自增取下标：
+
+	Pick:   seq[counter.Add(1) % len(seq)]   无锁、O(1)
+	Update: 加锁串行化写者，计算新表后 atomic.Pointer.Store 替换（copy-on-write），读者不受影响
+
+代价是内存 O(Σ权重/gcd)，权重很大且互质时调度表会很长，超过 MaxScheduleLen 时按比例缩小权重。
+节点数超过 MaxScheduleLen 时每个节点连一个位置都分不到，改为按累计权重二分查找：
+仍然无锁、比例精确，但同一节点的选择是连续的一段，不再平滑。
+权重变化后计数器不归零，新表从任意位置开始，长期分布不受影响。
+*/
+
+// MaxScheduleLen 调度表的最大长度
+const MaxScheduleLen = 1 << 16
+
+// maxCumWeight 累计权重的上限，超过时按比例缩小，保证求和不溢出 uint64
+const maxCumWeight = 1 << 62
+
+type schedule struct {
+	seq   []string
+	nodes []Node
+	cum   []uint64 // 节点数超过 MaxScheduleLen 时代替 seq：cum[i] 为前 i+1 个节点的权重和
+}
+
+func newSchedule(nodes []Node) *schedule {
+	if len(nodes) > MaxScheduleLen {
+		return &schedule{nodes: nodes, cum: cumulativeWeights(nodes)}
+	}
+	return &schedule{seq: buildSchedule(nodes), nodes: nodes}
+}
+
+// ScheduledPicker 无锁加权选择器，选择顺序与 weighted.SW 相同
+type ScheduledPicker struct {
+	state   atomic.Pointer[schedule]
+	counter atomic.Uint64
+
+	mu sync.Mutex // 串行化 Update
+}
+
+// NewScheduledPicker 用初始节点创建
+func NewScheduledPicker(nodes []Node) *ScheduledPicker {
+	p := &ScheduledPicker{}
+	p.Update(nodes)
+	return p
+}
+
+// Pick 实现 Picker，key 被忽略
+func (p *ScheduledPicker) Pick(string) (string, func(), error) {
+	s := p.state.Load()
+	i := p.counter.Add(1) - 1
+	if s.cum != nil {
+		x := i % s.cum[len(s.cum)-1]
+		j := sort.Search(len(s.cum), func(j int) bool { return s.cum[j] > x })
+		return s.nodes[j].Addr, noop, nil
+	}
+	if len(s.seq) == 0 {
+		return "", nil, ErrNoNodes
+	}
+	return s.seq[i%uint64(len(s.seq))], noop, nil
+}
+
+// Nodes 当前节点
+func (p *ScheduledPicker) Nodes() []Node {
+	return append([]Node(nil), p.state.Load().nodes...)
+}
+
+// Update 整体替换节点列表
+func (p *ScheduledPicker) Update(nodes []Node) {
+	nodes = validNodes(nodes)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state.Store(newSchedule(nodes))
+}
+
+// Add 加入或更新一个节点，weight <= 0 时删除
+func (p *ScheduledPicker) Add(addr string, weight int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old := p.state.Load().nodes
+	nodes := make([]Node, 0, len(old)+1)
+	found := false
+	for _, n := range old {
+		if n.Addr == addr {
+			found = true
+			n.Weight = weight
+		}
+		if n.Weight > 0 {
+			nodes = append(nodes, n)
+		}
+	}
+	if !found && weight > 0 {
+		nodes = append(nodes, Node{Addr: addr, Weight: weight})
+	}
+	p.state.Store(newSchedule(nodes))
+}
+
+// Remove 删除节点
+func (p *ScheduledPicker) Remove(addr string) {
+	p.Add(addr, 0)
+}
+
+// buildSchedule 按平滑加权轮询算出一个完整周期的选择序列，要求 len(nodes) <= MaxScheduleLen
+func buildSchedule(nodes []Node) []string {
+	weights := make([]int, len(nodes))
+	g := 0
+	for i, n := range nodes {
+		weights[i] = n.Weight
+		g = gcd(g, n.Weight)
+	}
+	sum := 0.0 // 权重很大时 int 求和会溢出
+	for i := range weights {
+		weights[i] /= g
+		sum += float64(weights[i])
+	}
+	if sum > MaxScheduleLen {
+		// 按比例缩小到 MaxScheduleLen-节点数 以内，每个节点再保底 1，总和不超过 MaxScheduleLen；
+		// 用 float64 计算，避免 weight×MaxScheduleLen 溢出
+		budget := float64(MaxScheduleLen - len(nodes))
+		for i := range weights {
+			weights[i] = max(int(float64(weights[i])*budget/sum), 1)
+		}
+	}
+	total := sumInts(weights)
+
+	seq := make([]string, 0, total)
+	current := make([]int, len(nodes))
+	for k := 0; k < total; k++ {
+		best := -1
+		for i := range nodes {
+			current[i] += weights[i]
+			if best < 0 || current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		seq = append(seq, nodes[best].Addr)
+	}
+	return seq
+}
+
+// cumulativeWeights 累计权重，总和超过 maxCumWeight 时按比例缩小，每个节点保底 1
+func cumulativeWeights(nodes []Node) []uint64 {
+	sum := 0.0
+	for _, n := range nodes {
+		sum += float64(n.Weight)
+	}
+	scale := 1.0
+	if sum > maxCumWeight {
+		scale = maxCumWeight / sum
+	}
+	cum := make([]uint64, len(nodes))
+	var acc uint64
+	for i, n := range nodes {
+		w := uint64(n.Weight)
+		if scale < 1 {
+			w = max(uint64(float64(n.Weight)*scale), 1)
+		}
+		acc += w
+		cum[i] = acc
+	}
+	return cum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func sumInts(xs []int) int {
+	s := 0
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
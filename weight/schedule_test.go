@@ -0,0 +1,170 @@
+package weightsnippet
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/smallnest/weighted"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduledPicker(t *testing.T) {
+	t.Run("与weighted.SW的顺序一致", func(t *testing.T) {
+		nodes := []Node{{"a", 10}, {"b", 4}, {"c", 6}}
+		p := NewScheduledPicker(nodes)
+		sw := &weighted.SW{}
+		for _, n := range nodes {
+			sw.Add(n.Addr, n.Weight)
+		}
+		assert.Len(t, p.state.Load().seq, 10, "按 gcd 约分后周期为 5+2+3")
+		for i := 0; i < 50; i++ {
+			addr, _, err := p.Pick("")
+			assert.NoError(t, err)
+			assert.Equal(t, sw.Next(), addr)
+		}
+	})
+
+	t.Run("动态增删节点", func(t *testing.T) {
+		p := NewScheduledPicker(nil)
+		_, _, err := p.Pick("")
+		assert.ErrorIs(t, err, ErrNoNodes)
+
+		p.Add("a", 1)
+		p.Add("b", 3)
+		assert.Equal(t, map[string]int{"a": 25, "b": 75}, countOf(pickN(p, 100)))
+		p.Add("a", 3)
+		assert.Equal(t, map[string]int{"a": 50, "b": 50}, countOf(pickN(p, 100)))
+		p.Remove("b")
+		assert.Equal(t, []Node{{"a", 3}}, p.Nodes())
+		assert.Equal(t, map[string]int{"a": 10}, countOf(pickN(p, 10)))
+	})
+
+	t.Run("权重过大时缩小", func(t *testing.T) {
+		p := NewScheduledPicker([]Node{{"a", 1000003}, {"b", 999983}, {"c", 1}})
+		seq := p.state.Load().seq
+		assert.LessOrEqual(t, len(seq), MaxScheduleLen)
+		counts := countOf(seq)
+		assert.InDelta(t, 1.0, float64(counts["a"])/float64(counts["b"]), 0.001)
+		assert.Equal(t, 1, counts["c"])
+	})
+
+	t.Run("权重接近MaxInt时不溢出", func(t *testing.T) {
+		p := NewScheduledPicker([]Node{{"a", math.MaxInt}, {"b", math.MaxInt - 1}, {"c", 1}})
+		seq := p.state.Load().seq
+		assert.LessOrEqual(t, len(seq), MaxScheduleLen)
+		counts := countOf(seq)
+		assert.InDelta(t, 1.0, float64(counts["a"])/float64(counts["b"]), 0.001)
+		assert.Equal(t, 1, counts["c"])
+	})
+
+	t.Run("节点多于MaxScheduleLen时按累计权重选择", func(t *testing.T) {
+		nodes := make([]Node, MaxScheduleLen+1)
+		for i := range nodes {
+			nodes[i] = Node{fmt.Sprintf("n%d", i), 1}
+		}
+		nodes[0].Weight = 3
+		p := NewScheduledPicker(nodes)
+		s := p.state.Load()
+		assert.Nil(t, s.seq)
+		// 一个周期内每个节点恰好按权重出现
+		counts := countOf(pickN(p, MaxScheduleLen+3))
+		assert.Len(t, counts, len(nodes))
+		assert.Equal(t, 3, counts["n0"])
+		assert.Equal(t, 1, counts[fmt.Sprintf("n%d", MaxScheduleLen)])
+	})
+
+	t.Run("节点多且权重巨大时累计和不溢出", func(t *testing.T) {
+		nodes := make([]Node, MaxScheduleLen+1)
+		for i := range nodes {
+			nodes[i] = Node{fmt.Sprintf("n%d", i), math.MaxInt}
+		}
+		cum := NewScheduledPicker(nodes).state.Load().cum
+		assert.Len(t, cum, len(nodes))
+		for i := 1; i < len(cum); i++ {
+			assert.Greater(t, cum[i], cum[i-1])
+		}
+	})
+
+	t.Run("并发选择严格按权重", func(t *testing.T) {
+		p := NewScheduledPicker([]Node{{"a", 5}, {"b", 2}, {"c", 3}})
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			counts = make(map[string]int)
+		)
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				local := countOf(pickN(p, 1000))
+				mu.Lock()
+				for k, v := range local {
+					counts[k] += v
+				}
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		// 计数器保证每个下标恰好被取一次，8000 次正好是 800 个完整周期
+		assert.Equal(t, map[string]int{"a": 4000, "b": 1600, "c": 2400}, counts)
+	})
+
+	t.Run("选择时更新", func(t *testing.T) {
+		p := NewScheduledPicker([]Node{{"a", 1}})
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, addr := range pickN(p, 1000) {
+					assert.Contains(t, []string{"a", "b"}, addr)
+				}
+			}()
+		}
+		for i := 0; i < 100; i++ {
+			p.Add("b", i%3)
+		}
+		wg.Wait()
+	})
+}
+
+func pickN(p Picker, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i], _, _ = p.Pick("")
+	}
+	return out
+}
+
+// go test -bench=Pick -cpu=1,8,32 ./weight
+// 加锁的 SW 每次选择 O(n) 且所有 goroutine 争一把锁，核数越多越慢；调度表只有一次原子自增
+
+func benchNodes() []Node {
+	return makeNodes(5, 2, 3, 7, 1, 4, 6, 2, 3, 8)
+}
+
+func BenchmarkPickMutexSW(b *testing.B) {
+	var mu sync.Mutex
+	sw := &weighted.SW{}
+	for _, n := range benchNodes() {
+		sw.Add(n.Addr, n.Weight)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+			_ = sw.Next()
+			mu.Unlock()
+		}
+	})
+}
+
+func BenchmarkPickScheduled(b *testing.B) {
+	p := NewScheduledPicker(benchNodes())
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _, _ = p.Pick("")
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package weightsnippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+/*
+流量分割：灰度发布、A/B 实验按权重把用户分到不同的变体（variant）。
+
+	{
+	  "name": "checkout-v2",
+	  "variants": [{"name": "canary", "weight": 0}, {"name": "stable", "weight": 100}],
+	  "ramp": [
+	    {"at": "2025-09-14T10:00:00Z", "weights": {"canary": 1, "stable": 99}},
+	    {"at": "2025-09-15T10:00:00Z", "weights": {"canary": 5, "stable": 95}},
+	    {"at": "2025-09-16T10:00:00Z", "weights": {"canary": 50, "stable": 50}}
+	  ]
+	}
+
+- 粘性分配：point = hash(name + key) 映射到 [0, 1)，按变体顺序把 [0, 1) 切成与权重成比例的区间，
+  point 落在哪个区间就是哪个变体。不依赖任何状态，同一个用户在任何实例上、任何时候都得到同一个变体
+- name 参与哈希：不同实验之间的分组互相独立，不会总是同一批用户进灰度
+- 逐步放量：ramp 按时间生效，到达 at 后使用该阶段的权重，之前使用 variants 里的初始权重。
+  区间按变体顺序从 0 开始排，排在前面的变体（canary）权重只增不减时，
+  已经进入 canary 的用户放量后仍在 canary，只有 stable 的用户会被移过来
+- 曝光计数：每次 Assign 给对应变体计数，用于核对实际流量占比
+*/
+
+// Variant 变体及其初始权重
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// RampStep 放量阶段，从 At 开始按 Weights 分配，未列出的变体权重为 0
+type RampStep struct {
+	At      time.Time      `json:"at"`
+	Weights map[string]int `json:"weights"`
+}
+
+// SplitConfig 流量分割配置
+type SplitConfig struct {
+	Name     string     `json:"name"`
+	Variants []Variant  `json:"variants"`
+	Ramp     []RampStep `json:"ramp,omitempty"`
+}
+
+// ParseSplitConfig 解析 JSON 配置并校验
+func ParseSplitConfig(data []byte) (SplitConfig, error) {
+	var cfg SplitConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return SplitConfig{}, fmt.Errorf("parse split config: %w", err)
+	}
+	if _, err := compileStages(cfg); err != nil {
+		return SplitConfig{}, err
+	}
+	return cfg, nil
+}
+
+// LoadSplitFile 读取并解析 JSON 配置文件
+func LoadSplitFile(name string) (SplitConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return SplitConfig{}, err
+	}
+	return ParseSplitConfig(data)
+}
+
+// stage 一个放量阶段，bounds[i] 是第 i 个变体区间的右端点
+type stage struct {
+	at     time.Time
+	bounds []float64
+}
+
+func compileStages(cfg SplitConfig) ([]stage, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("split: name is required")
+	}
+	if len(cfg.Variants) == 0 {
+		return nil, errors.New("split: at least one variant is required")
+	}
+	index := make(map[string]int, len(cfg.Variants))
+	weights := make([]int, len(cfg.Variants))
+	for i, v := range cfg.Variants {
+		if v.Name == "" {
+			return nil, fmt.Errorf("split: variant %d has no name", i)
+		}
+		if _, ok := index[v.Name]; ok {
+			return nil, fmt.Errorf("split: duplicate variant %q", v.Name)
+		}
+		index[v.Name] = i
+		weights[i] = v.Weight
+	}
+
+	first, err := compileStage(time.Time{}, weights)
+	if err != nil {
+		return nil, fmt.Errorf("split: variants: %w", err)
+	}
+	stages := []stage{first}
+	for i, step := range cfg.Ramp {
+		if i > 0 && !step.At.After(cfg.Ramp[i-1].At) {
+			return nil, fmt.Errorf("split: ramp step %d: at must be after the previous step", i)
+		}
+		weights := make([]int, len(cfg.Variants))
+		for name, w := range step.Weights {
+			j, ok := index[name]
+			if !ok {
+				return nil, fmt.Errorf("split: ramp step %d: unknown variant %q", i, name)
+			}
+			weights[j] = w
+		}
+		s, err := compileStage(step.At, weights)
+		if err != nil {
+			return nil, fmt.Errorf("split: ramp step %d: %w", i, err)
+		}
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
+
+func compileStage(at time.Time, weights []int) (stage, error) {
+	total := 0
+	for _, w := range weights {
+		if w < 0 {
+			return stage{}, errors.New("negative weight")
+		}
+		total += w
+	}
+	if total == 0 {
+		return stage{}, errors.New("total weight is 0")
+	}
+	bounds := make([]float64, len(weights))
+	sum := 0
+	for i, w := range weights {
+		sum += w
+		bounds[i] = float64(sum) / float64(total)
+	}
+	return stage{at: at, bounds: bounds}, nil
+}
+
+// Splitter 按配置把 key 分配到变体，并发安全
+type Splitter struct {
+	name      string
+	variants  []string
+	stages    []stage
+	exposures []atomic.Int64
+	clock     timesnippet.Clock
+}
+
+// NewSplitter 校验配置并创建，clock 为空时使用系统时间
+func NewSplitter(cfg SplitConfig, clock timesnippet.Clock) (*Splitter, error) {
+	stages, err := compileStages(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = timesnippet.RealClock
+	}
+	s := &Splitter{
+		name:      cfg.Name,
+		stages:    stages,
+		exposures: make([]atomic.Int64, len(cfg.Variants)),
+		clock:     clock,
+	}
+	for _, v := range cfg.Variants {
+		s.variants = append(s.variants, v.Name)
+	}
+	return s, nil
+}
+
+// current 当前生效的阶段
+func (s *Splitter) current() stage {
+	now := s.clock.Now()
+	i := len(s.stages) - 1
+	for i > 0 && now.Before(s.stages[i].at) {
+		i--
+	}
+	return s.stages[i]
+}
+
+// point key 在 [0, 1) 上的位置
+func (s *Splitter) point(key string) float64 {
+	return float64(hash64(s.name+"\x00"+key)>>11) / (1 << 53)
+}
+
+func (s *Splitter) lookup(key string) int {
+	p := s.point(key)
+	bounds := s.current().bounds
+	for i, b := range bounds {
+		if p < b {
+			return i
+		}
+	}
+	return len(bounds) - 1
+}
+
+// Assign 返回 key 的变体并计一次曝光
+func (s *Splitter) Assign(key string) string {
+	i := s.lookup(key)
+	s.exposures[i].Add(1)
+	return s.variants[i]
+}
+
+// Variant 返回 key 的变体，不计曝光
+func (s *Splitter) Variant(key string) string {
+	return s.variants[s.lookup(key)]
+}
+
+// Shares 当前阶段各变体的流量占比
+func (s *Splitter) Shares() map[string]float64 {
+	bounds := s.current().bounds
+	out := make(map[string]float64, len(bounds))
+	prev := 0.0
+	for i, b := range bounds {
+		out[s.variants[i]] = b - prev
+		prev = b
+	}
+	return out
+}
+
+// Exposures 各变体的曝光次数
+func (s *Splitter) Exposures() map[string]int64 {
+	out := make(map[string]int64, len(s.variants))
+	for i, name := range s.variants {
+		out[name] = s.exposures[i].Load()
+	}
+	return out
+}
+
+// Variants 变体名，按配置顺序
+func (s *Splitter) Variants() []string {
+	return slices.Clone(s.variants)
+}
@@ -0,0 +1,159 @@
+package weightsnippet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	timesnippet "github.com/A0dongq1N/golang_snippet/time"
+)
+
+const rampConfig = `{
+  "name": "checkout-v2",
+  "variants": [{"name": "canary", "weight": 0}, {"name": "stable", "weight": 100}],
+  "ramp": [
+    {"at": "2025-09-14T10:00:00Z", "weights": {"canary": 1, "stable": 99}},
+    {"at": "2025-09-15T10:00:00Z", "weights": {"canary": 5, "stable": 95}},
+    {"at": "2025-09-16T10:00:00Z", "weights": {"canary": 50, "stable": 50}}
+  ]
+}`
+
+func users(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("uid-%d", i)
+	}
+	return out
+}
+
+func TestSplitterSticky(t *testing.T) {
+	cfg := SplitConfig{Name: "exp", Variants: []Variant{{"a", 50}, {"b", 30}, {"c", 20}}}
+	s1, err := NewSplitter(cfg, nil)
+	assert.NoError(t, err)
+	s2, _ := NewSplitter(cfg, nil)
+
+	t.Run("同一用户总是同一变体", func(t *testing.T) {
+		for _, u := range users(1000) {
+			v := s1.Assign(u)
+			assert.Equal(t, v, s1.Assign(u))
+			assert.Equal(t, v, s2.Variant(u), "另一个实例结果相同")
+		}
+	})
+
+	t.Run("不同实验互相独立", func(t *testing.T) {
+		other, _ := NewSplitter(SplitConfig{Name: "other", Variants: cfg.Variants}, nil)
+		same := 0
+		for _, u := range users(10000) {
+			if s1.Variant(u) == other.Variant(u) {
+				same++
+			}
+		}
+		// 独立时相同的概率 0.5²+0.3²+0.2² = 0.38
+		assert.InDelta(t, 0.38, float64(same)/10000, 0.02)
+	})
+}
+
+func TestSplitterShares(t *testing.T) {
+	s, err := NewSplitter(SplitConfig{Name: "exp", Variants: []Variant{{"a", 50}, {"b", 30}, {"c", 20}}}, nil)
+	assert.NoError(t, err)
+	const n = 100000
+	for _, u := range users(n) {
+		s.Assign(u)
+	}
+	exposures := s.Exposures()
+	t.Log(exposures)
+	for name, share := range s.Shares() {
+		assert.InDelta(t, share, float64(exposures[name])/n, 0.01, name)
+	}
+	assert.EqualValues(t, n, exposures["a"]+exposures["b"]+exposures["c"])
+}
+
+func TestSplitterRamp(t *testing.T) {
+	cfg, err := ParseSplitConfig([]byte(rampConfig))
+	assert.NoError(t, err)
+	clock := timesnippet.NewFakeClock(clockStart) // 2025-09-14 00:00 UTC，第一阶段之前
+	s, err := NewSplitter(cfg, clock)
+	assert.NoError(t, err)
+	us := users(20000)
+
+	canary := func() map[string]bool {
+		out := make(map[string]bool)
+		for _, u := range us {
+			if s.Variant(u) == "canary" {
+				out[u] = true
+			}
+		}
+		return out
+	}
+
+	assert.Empty(t, canary())
+	var prev map[string]bool
+	for _, c := range []struct {
+		at    time.Time
+		share float64
+	}{
+		{time.Date(2025, 9, 14, 10, 0, 0, 0, time.UTC), 0.01},
+		{time.Date(2025, 9, 15, 12, 0, 0, 0, time.UTC), 0.05},
+		{time.Date(2025, 9, 16, 10, 0, 0, 0, time.UTC), 0.50},
+	} {
+		clock.Set(c.at)
+		assert.InDelta(t, c.share, s.Shares()["canary"], 1e-9)
+		cur := canary()
+		assert.InDelta(t, c.share, float64(len(cur))/float64(len(us)), c.share*0.2+0.005)
+		for u := range prev {
+			assert.True(t, cur[u], "%s 放量后应仍在 canary", u)
+		}
+		prev = cur
+	}
+}
+
+func TestSplitterConcurrent(t *testing.T) {
+	s, _ := NewSplitter(SplitConfig{Name: "exp", Variants: []Variant{{"a", 1}, {"b", 1}}}, nil)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, u := range users(1000) {
+				s.Assign(u)
+			}
+		}()
+	}
+	wg.Wait()
+	e := s.Exposures()
+	assert.EqualValues(t, 8000, e["a"]+e["b"])
+}
+
+func TestSplitConfig(t *testing.T) {
+	t.Run("从文件加载", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "split.json")
+		os.WriteFile(path, []byte(rampConfig), 0o644)
+		cfg, err := LoadSplitFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "checkout-v2", cfg.Name)
+		assert.Len(t, cfg.Ramp, 3)
+		assert.Equal(t, 5, cfg.Ramp[1].Weights["canary"])
+	})
+
+	for name, data := range map[string]string{
+		"缺少名字":   `{"variants": [{"name": "a", "weight": 1}]}`,
+		"没有变体":   `{"name": "x"}`,
+		"变体重名":   `{"name": "x", "variants": [{"name": "a", "weight": 1}, {"name": "a", "weight": 1}]}`,
+		"权重为负":   `{"name": "x", "variants": [{"name": "a", "weight": -1}, {"name": "b", "weight": 2}]}`,
+		"总权重为 0": `{"name": "x", "variants": [{"name": "a", "weight": 0}]}`,
+		"未知字段":   `{"name": "x", "variants": [{"name": "a", "weight": 1}], "salt": "y"}`,
+		"未知变体":   `{"name": "x", "variants": [{"name": "a", "weight": 1}], "ramp": [{"at": "2025-09-14T00:00:00Z", "weights": {"b": 1}}]}`,
+		"阶段乱序": `{"name": "x", "variants": [{"name": "a", "weight": 1}], "ramp": [
+			{"at": "2025-09-15T00:00:00Z", "weights": {"a": 1}}, {"at": "2025-09-14T00:00:00Z", "weights": {"a": 1}}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSplitConfig([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
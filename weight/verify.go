@@ -0,0 +1,139 @@
+package weightsnippet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	mathsnippet "github.com/A0dongq1N/golang_snippet/math"
+)
+
+/*
+分布验证：让任意 Picker 选 N 次，对比各节点的实际占比与按权重的期望占比。
+
+- MaxRun：同一节点连续被选中的最长次数，即注释里说的“平滑度”，越小越平滑
+- 卡方拟合优度检验：χ² = Σ (实际-期望)²/期望，自由度 = 节点数-1，
+  PValue 很小（如 < 0.01）说明实际分布与权重显著不符
+- Timeline 把选择序列画成 ASCII 时间线，每个节点一行
+*/
+
+// NodeReport 单个节点的统计
+type NodeReport struct {
+	Addr     string
+	Weight   int
+	Count    int
+	Share    float64 // 实际占比
+	Expected float64 // 按权重的期望占比
+	MaxRun   int     // 最长连续命中次数
+}
+
+// Report 分布验证结果
+type Report struct {
+	Picks     int
+	Nodes     []NodeReport
+	ChiSquare float64
+	DF        int
+	PValue    float64
+	Sequence  []string // 选择序列
+}
+
+// Verify 对 p 执行 n 次选择，第 i 次的 key 为 i 的十进制字符串，done 立即调用
+func Verify(p Picker, nodes []Node, n int) (Report, error) {
+	nodes = validNodes(nodes)
+	r := Report{Picks: n, Sequence: make([]string, 0, n)}
+	index := make(map[string]int, len(nodes))
+	total := 0
+	for i, node := range nodes {
+		index[node.Addr] = i
+		total += node.Weight
+		r.Nodes = append(r.Nodes, NodeReport{Addr: node.Addr, Weight: node.Weight})
+	}
+
+	run, last := 0, ""
+	for i := 0; i < n; i++ {
+		addr, done, err := p.Pick(strconv.Itoa(i))
+		if err != nil {
+			return Report{}, err
+		}
+		done()
+		idx, ok := index[addr]
+		if !ok {
+			return Report{}, fmt.Errorf("picker returned unknown node %q", addr)
+		}
+		r.Sequence = append(r.Sequence, addr)
+		if addr == last {
+			run++
+		} else {
+			run, last = 1, addr
+		}
+		nr := &r.Nodes[idx]
+		nr.Count++
+		nr.MaxRun = max(nr.MaxRun, run)
+	}
+
+	for i := range r.Nodes {
+		nr := &r.Nodes[i]
+		nr.Expected = float64(nr.Weight) / float64(total)
+		if n > 0 {
+			nr.Share = float64(nr.Count) / float64(n)
+		}
+		expected := nr.Expected * float64(n)
+		if expected > 0 {
+			diff := float64(nr.Count) - expected
+			r.ChiSquare += diff * diff / expected
+		}
+	}
+	r.DF = max(len(r.Nodes)-1, 0)
+	r.PValue = mathsnippet.ChiSquareSurvival(r.ChiSquare, r.DF)
+	return r, nil
+}
+
+// MaxRun 所有节点中最长的连续命中次数
+func (r Report) MaxRun() int {
+	longest := 0
+	for _, n := range r.Nodes {
+		longest = max(longest, n.MaxRun)
+	}
+	return longest
+}
+
+// String 表格形式的报告
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s %6s %8s %8s %8s %6s\n", "node", "weight", "count", "share", "expect", "run")
+	for _, n := range r.Nodes {
+		fmt.Fprintf(&b, "%-16s %6d %8d %7.2f%% %7.2f%% %6d\n",
+			n.Addr, n.Weight, n.Count, n.Share*100, n.Expected*100, n.MaxRun)
+	}
+	fmt.Fprintf(&b, "picks=%d chi2=%.4f df=%d p=%.4f", r.Picks, r.ChiSquare, r.DF, r.PValue)
+	return b.String()
+}
+
+// Timeline 前 width 次选择的 ASCII 时间线，每个节点一行，命中为 #
+func (r Report) Timeline(width int) string {
+	seq := r.Sequence
+	if width > 0 && len(seq) > width {
+		seq = seq[:width]
+	}
+	nameWidth := 0
+	for _, n := range r.Nodes {
+		nameWidth = max(nameWidth, len(n.Addr))
+	}
+
+	var b strings.Builder
+	for i, n := range r.Nodes {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%-*s |", nameWidth, n.Addr)
+		for _, addr := range seq {
+			if addr == n.Addr {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('|')
+	}
+	return b.String()
+}
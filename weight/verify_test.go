@@ -0,0 +1,62 @@
+package weightsnippet
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func balancerOf(alg Algorithm, nodes []Node) *Balancer {
+	b := NewBalancer(BalancerConfig{Algorithm: alg})
+	for _, n := range nodes {
+		b.Add(n.Addr, n.Weight)
+	}
+	return b
+}
+
+// 注释里的例子 a:5 b:2 c:3：两者都严格按权重分配，但 SW 更平滑
+func TestVerifySmoothness(t *testing.T) {
+	nodes := []Node{{"a", 5}, {"b", 2}, {"c", 3}}
+
+	sw, err := Verify(balancerOf(AlgorithmSW, nodes), nodes, 1000)
+	assert.NoError(t, err)
+	rrw, err := Verify(balancerOf(AlgorithmRRW, nodes), nodes, 1000)
+	assert.NoError(t, err)
+	t.Logf("SW\n%s\n%s", sw, sw.Timeline(30))
+	t.Logf("RRW\n%s\n%s", rrw, rrw.Timeline(30))
+
+	for _, r := range []Report{sw, rrw} {
+		assert.Equal(t, 0.0, r.ChiSquare, "每 10 次一个完整周期，1000 次严格按权重")
+		assert.Equal(t, 1.0, r.PValue)
+		assert.Equal(t, []int{500, 200, 300}, []int{r.Nodes[0].Count, r.Nodes[1].Count, r.Nodes[2].Count})
+	}
+	assert.Equal(t, 2, sw.MaxRun())
+	assert.Equal(t, 3, rrw.MaxRun())
+	assert.Less(t, sw.MaxRun(), rrw.MaxRun())
+	assert.Equal(t, "a |#..##.#..#|\nb |..#....#..|\nc |.#...#..#.|", sw.Timeline(10))
+}
+
+func TestVerifyChiSquare(t *testing.T) {
+	nodes := makeNodes(1, 2, 3, 4)
+
+	t.Run("按权重随机时不拒绝", func(t *testing.T) {
+		r, err := Verify(NewRandomWeighted(nodes, rand.NewPCG(1, 2)), nodes, 100000)
+		assert.NoError(t, err)
+		t.Log("\n" + r.String())
+		assert.Equal(t, 3, r.DF)
+		assert.Greater(t, r.PValue, 0.01)
+	})
+
+	t.Run("权重不符时拒绝", func(t *testing.T) {
+		// 实际按 1:1:1:1 分配，对照 1:2:3:4 的期望
+		r, err := Verify(NewRandomWeighted(makeNodes(1, 1, 1, 1), rand.NewPCG(1, 2)), nodes, 10000)
+		assert.NoError(t, err)
+		assert.Less(t, r.PValue, 1e-6)
+	})
+
+	t.Run("未知节点报错", func(t *testing.T) {
+		_, err := Verify(NewRandomWeighted(makeNodes(1, 1), nil), makeNodes(1), 10)
+		assert.Error(t, err)
+	})
+}
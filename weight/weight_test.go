@@ -20,6 +20,7 @@ import (
 
 // 相同点
 // 都是 O(n) 选择；都非并发安全，需在并发调用 Next() 时加锁保护。
+// 并发场景可直接使用 balancer.go 中的 Balancer，它在锁内包装了 SW/RRW，并支持动态权重和被动健康检查。
 
 // 如何选择
 // 需要“更均匀的瞬时分布/更平滑的体验”→ 选 SW